@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/jnaraujo/seekr/internal/embeddings"
+	"github.com/jnaraujo/seekr/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the cache of document embeddings.",
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:         "stats",
+	Short:       "Display how much the embedding cache holds.",
+	Annotations: map[string]string{storeAnnotation: storeNone},
+	Run: func(cmd *cobra.Command, args []string) {
+		cache, err := openCache()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		stats, err := cache.Stats()
+		if err != nil {
+			fmt.Printf("failed to read cache: %v\n", err)
+			return
+		}
+
+		dir, _ := cacheDir()
+		fmt.Printf("Cache Path: %s\n", dir)
+		fmt.Printf("Entries: %d\n", stats.Entries)
+		fmt.Printf("Size: %s of %s\n", formatBytes(stats.Size), formatBytes(stats.MaxSize))
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:         "clear",
+	Short:       "Remove every entry of the embedding cache.",
+	Annotations: map[string]string{storeAnnotation: storeNone},
+	Run: func(cmd *cobra.Command, args []string) {
+		cache, err := openCache()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		stats, _ := cache.Stats()
+		if err := cache.Clear(); err != nil {
+			fmt.Printf("failed to clear cache: %v\n", err)
+			return
+		}
+		fmt.Printf("Cache cleared: %d entries (%s) removed\n", stats.Entries, formatBytes(stats.Size))
+	},
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheStatsCmd, cacheClearCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
+
+// cacheDir returns where the embedding cache is kept, next to the store.
+func cacheDir() (string, error) {
+	storePath, err := storage.DefaultStorePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(storePath), "cache"), nil
+}
+
+func openCache() (*embeddings.Cache, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("error getting cache path: %w", err)
+	}
+	cache, err := embeddings.OpenCache(dir, cacheSize<<20)
+	if err != nil {
+		return nil, fmt.Errorf("error opening cache: %w", err)
+	}
+	return cache, nil
+}
@@ -0,0 +1,286 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jnaraujo/seekr/internal/embeddings"
+	"github.com/jnaraujo/seekr/internal/extract"
+	"github.com/jnaraujo/seekr/internal/storage"
+	"github.com/jnaraujo/seekr/internal/textsplitter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+// seekr runs the command line with args against a store in the temporary config directory set up
+// by newWorkspace, embedding with the hashing provider unless told otherwise, and returns what it printed.
+func seekr(t *testing.T, args ...string) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	printed := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		printed <- buf.String()
+	}()
+
+	if !slices.Contains(args, "--provider") {
+		args = append(args, "--provider", "hashing")
+	}
+	resetFlags(t, rootCmd)
+	rootCmd.SetArgs(args)
+	err = rootCmd.Execute()
+	if store != nil {
+		store.Close()
+		store = nil
+	}
+
+	w.Close()
+	os.Stdout = stdout
+	out := <-printed
+	assert.NoError(t, err, out)
+	return out
+}
+
+// resetFlags puts every flag of cmd and its subcommands back to its default, as if the command
+// line ran in a process of its own, since the variables the flags set outlive a run.
+func resetFlags(t *testing.T, cmd *cobra.Command) {
+	t.Helper()
+	for _, flags := range []*pflag.FlagSet{cmd.PersistentFlags(), cmd.Flags()} {
+		flags.VisitAll(func(f *pflag.Flag) {
+			f.Changed = false
+			// a map flag merges what it is set to into the map it already holds, and its
+			// default "[]" does not parse, so the map is replaced below instead
+			if f.Value.Type() != "stringToString" {
+				assert.NoError(t, f.Value.Set(f.DefValue), f.Name)
+			}
+		})
+	}
+	for _, sub := range cmd.Commands() {
+		resetFlags(t, sub)
+	}
+	splitters = map[string]string{}
+}
+
+// newWorkspace points the store at a temporary directory and returns a directory of documents to index.
+func newWorkspace(t *testing.T) string {
+	config := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", config)
+	t.Setenv("HOME", config)
+	t.Setenv("AppData", config)
+
+	docs := t.TempDir()
+	files := map[string]string{
+		"gardening.md": "Tomatoes need full sun and regular watering.\nPrune the tomato plants weekly.",
+		"network.md":   "Configure the proxy server before installing packages.\nThe proxy listens on port 3128.",
+		"cooking.md":   "Knead the bread dough for ten minutes, then let it rise.",
+		".hidden.md":   "Hidden files are never indexed.",
+	}
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(docs, name), []byte(content), 0o644))
+	}
+	return docs
+}
+
+func TestIndexSearchRemove(t *testing.T) {
+	docs := newWorkspace(t)
+
+	out := seekr(t, "index", docs)
+	assert.Contains(t, out, "Indexing complete!")
+	assert.Equal(t, 3, strings.Count(out, "indexed successfully"))
+
+	out = seekr(t, "list")
+	assert.Contains(t, out, "Found 3 document(s)")
+	assert.NotContains(t, out, ".hidden.md")
+
+	out = seekr(t, "search", "which port does the proxy server use")
+	first := strings.SplitN(out, "(1)", 2)[1]
+	assert.Contains(t, strings.SplitN(first, "(2)", 2)[0], filepath.Join(docs, "network.md")+":1-2")
+	assert.Contains(t, out, "The proxy listens on port 3128.")
+
+	out = seekr(t, "index", filepath.Join(docs, "network.md"))
+	assert.Contains(t, out, "already indexed")
+
+	out = seekr(t, "remove", filepath.Join(docs, "network.md"))
+	assert.Contains(t, out, "Document removed successfully")
+
+	out = seekr(t, "search", "which port does the proxy server use")
+	assert.NotContains(t, out, "network.md")
+
+	out = seekr(t, "stats")
+	assert.Contains(t, out, "Total Documents: 2")
+	assert.Contains(t, out, "Model: seekr-hashing-v1")
+
+	seekr(t, "remove", docs)
+	out = seekr(t, "list")
+	assert.Contains(t, out, "No documents found.")
+}
+
+func TestSearchShowsBreadcrumbs(t *testing.T) {
+	newWorkspace(t)
+	notes := filepath.Join(t.TempDir(), "setup.md")
+	content := "# Setup\n\nInstall the tools.\n\n## Linux\n\n### Proxy\n\nExport HTTP_PROXY with the proxy port before installing.\n"
+	assert.NoError(t, os.WriteFile(notes, []byte(content), 0o644))
+
+	seekr(t, "index", notes)
+	out := seekr(t, "search", "proxy port")
+	assert.Contains(t, out, "(1) ")
+	assert.Contains(t, strings.SplitN(out, "(2)", 2)[0], notes+":7-9\n    Setup > Linux > Proxy\n")
+}
+
+func TestSearchShowsSymbols(t *testing.T) {
+	newWorkspace(t)
+	source := filepath.Join(t.TempDir(), "proxy.go")
+	content := "package proxy\n\nimport \"net/http\"\n\n// Dial connects through the proxy port.\nfunc Dial(port int) {\n\thttp.Get(\"http://proxy\")\n}\n\nfunc unrelated() {}\n"
+	assert.NoError(t, os.WriteFile(source, []byte(content), 0o644))
+
+	seekr(t, "index", source)
+	out := seekr(t, "search", "connects through the proxy port")
+	assert.Contains(t, strings.SplitN(out, "(2)", 2)[0], source+":5-8\n    Dial\n")
+}
+
+func TestIndexWithSplitterPerFileType(t *testing.T) {
+	newWorkspace(t)
+	notes := filepath.Join(t.TempDir(), "notes.txt")
+	content := "Tomatoes need sun. Tomatoes need water. Tomatoes need pruning.\n\nThe proxy listens on a port. The proxy needs a port. The proxy port is 3128."
+	assert.NoError(t, os.WriteFile(notes, []byte(content), 0o644))
+
+	out := seekr(t, "index", notes, "--splitter", "txt=semantic", "--chunk-min-size", "10")
+	assert.Contains(t, out, "indexed successfully")
+	out = seekr(t, "search", "proxy port")
+	// the topics of the file were split apart
+	assert.Contains(t, out, notes+":3\n    The proxy listens on a port.")
+
+	out = seekr(t, "index", notes, "--splitter", "txt=topics")
+	assert.Contains(t, out, "unknown splitter \"topics\"")
+}
+
+func TestIndexRejectsUnsupportedFiles(t *testing.T) {
+	newWorkspace(t)
+	image := filepath.Join(t.TempDir(), "image.png")
+	assert.NoError(t, os.WriteFile(image, []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\xff\xfe"), 0o644))
+
+	out := seekr(t, "index", image)
+	assert.Contains(t, out, "unsupported file type: image/png")
+}
+
+func TestSearchUsesStoreDimension(t *testing.T) {
+	docs := newWorkspace(t)
+
+	seekr(t, "index", docs, "--dimensions", "32")
+	out := seekr(t, "search", "proxy server")
+	assert.Contains(t, out, "network.md")
+	assert.Contains(t, seekr(t, "stats"), "Dimension: 32")
+}
+
+func TestDimensionsSentOnlyWhenPassed(t *testing.T) {
+	docs := newWorkspace(t)
+	var mu sync.Mutex
+	var requested []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input      []string `json:"input"`
+			Dimensions int      `json:"dimensions"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		mu.Lock()
+		requested = append(requested, req.Dimensions)
+		mu.Unlock()
+
+		type embedding struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		}
+		data := make([]embedding, len(req.Input))
+		for i := range req.Input {
+			vec := make([]float32, 8)
+			vec[i%8] = 1
+			data[i] = embedding{Index: i, Embedding: vec}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	defer server.Close()
+
+	provider := []string{"--provider", "openai", "--provider-url", server.URL + "/v1"}
+	seekr(t, append([]string{"index", docs}, provider...)...)
+	seekr(t, append([]string{"search", "proxy server"}, provider...)...)
+	assert.NotEmpty(t, requested)
+	for _, dimensions := range requested {
+		assert.Zero(t, dimensions)
+	}
+
+	seekr(t, append([]string{"search", "proxy server", "--dimensions", "8"}, provider...)...)
+	assert.Equal(t, 8, requested[len(requested)-1])
+}
+
+func TestIndexFileReportsStoreErrors(t *testing.T) {
+	docs := newWorkspace(t)
+	ds, err := storage.NewDiskStore(filepath.Join(t.TempDir(), "store.skdb"), storage.WithEmbedding("", 8))
+	assert.NoError(t, err)
+	saved := chunking
+	store, embedding = ds, embeddings.NewHashingProvider(16)
+	chunking.size, chunking.overlap, chunking.length = 200, 0, textsplitter.RuneLength
+	t.Cleanup(func() {
+		ds.Close()
+		store, embedding, chunking = nil, nil, saved
+	})
+
+	path := filepath.Join(docs, "cooking.md")
+	err = indexFile(context.Background(), path)
+	assert.ErrorIs(t, err, storage.ErrEmbeddingMismatch)
+	assert.ErrorContains(t, err, "failed to store document: embeddings do not match the store: chunk 0 has 16 dimensions, the store holds 8")
+}
+
+// slidesExtractor stands for an extractor of a format seekr does not know, registered by a
+// program built on it.
+type slidesExtractor struct{}
+
+func (slidesExtractor) Extract(content []byte) (extract.Result, error) {
+	return extract.Result{
+		Text:     "Quarterly revenue grew in every region.",
+		Metadata: extract.Metadata{Title: "Board Deck"},
+	}, nil
+}
+
+func TestIndexWithRegisteredExtractor(t *testing.T) {
+	newWorkspace(t)
+	extract.Register(".slides", slidesExtractor{})
+	t.Cleanup(func() { extract.Unregister(".slides") })
+	deck := filepath.Join(t.TempDir(), "deck.slides")
+	assert.NoError(t, os.WriteFile(deck, []byte("\x00\x01\xff\xfe"), 0o644))
+
+	out := seekr(t, "index", deck)
+	assert.Contains(t, out, "indexed successfully")
+
+	out = seekr(t, "list")
+	assert.Contains(t, out, "Board Deck - "+deck)
+}
+
+func TestDoctor(t *testing.T) {
+	newWorkspace(t)
+
+	out := seekr(t, "doctor")
+	assert.Contains(t, out, "✓ Model \"seekr-hashing-v1\" is available")
+	assert.Contains(t, out, "SeekR is ready")
+
+	server := httptest.NewServer(nil)
+	server.Close()
+	out = seekr(t, "doctor", "--provider", "ollama", "--provider-url", server.URL+"/api")
+	assert.Contains(t, out, "✗ Server "+server.URL+"/api is unreachable")
+	assert.Contains(t, out, "SeekR cannot embed")
+}
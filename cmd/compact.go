@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/jnaraujo/seekr/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var compactCmd = &cobra.Command{
+	Use:         "compact",
+	Short:       "Reclaim disk space taken by removed documents.",
+	Annotations: map[string]string{storeAnnotation: storeWrite},
+	Run: func(cmd *cobra.Command, args []string) {
+		compactor, ok := store.(storage.Compactor)
+		if !ok {
+			fmt.Println("The current store does not support compaction.")
+			return
+		}
+
+		stats, err := compactor.Compact(cmd.Context())
+		if err != nil {
+			fmt.Printf("failed to compact store: %v\n", err)
+			return
+		}
+
+		fmt.Printf("Store compacted: %s -> %s (%s reclaimed)\n",
+			formatBytes(stats.BytesBefore), formatBytes(stats.BytesAfter), formatBytes(stats.Reclaimed()))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(compactCmd)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jnaraujo/seekr/internal/config"
+	"github.com/jnaraujo/seekr/internal/embeddings"
+	"github.com/jnaraujo/seekr/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var pullMissing bool
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that the embedding provider is ready to index and search.",
+	Long: `Check that the server of the embedding provider answers, that it has the model and that the
+embeddings of the model fit the store. A model missing from Ollama can be pulled on the spot.`,
+	Example: "seekr doctor --model mxbai-embed-large --pull",
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Printf("Provider: %s\n", providerName)
+		if !checkProvider(cmd.Context(), true) {
+			fmt.Println("\nSeekR cannot embed until the problems above are fixed.")
+			return
+		}
+		fmt.Println("\nSeekR is ready to index and search.")
+	},
+}
+
+func init() {
+	doctorCmd.Flags().BoolVar(&pullMissing, "pull", false, "pull a missing model without asking")
+	rootCmd.AddCommand(doctorCmd)
+}
+
+// checkProvider prints what the health check of the provider found and reports whether it is
+// ready, offering to pull the model when the server is missing it and allowPull is set.
+func checkProvider(ctx context.Context, allowPull bool) bool {
+	health, err := embedding.Health(ctx)
+
+	if health.Server != "" {
+		if !health.Reachable {
+			check(false, "Server %s is unreachable: %v", health.Server, err)
+			fmt.Println("  Make sure the server is running, or pass its address with --provider-url.")
+			return false
+		}
+		check(true, "Server %s is reachable", health.Server)
+	}
+
+	if !health.ModelAvailable {
+		if !errors.Is(err, embeddings.ErrModelNotFound) {
+			check(false, "Model %q cannot be used: %v", health.Model, err)
+			return false
+		}
+		check(false, "Model %q is missing", health.Model)
+
+		puller, ok := embedding.(embeddings.ModelPuller)
+		if !ok || !allowPull {
+			return false
+		}
+		if !confirmPull(health.Model) {
+			return false
+		}
+		if err := puller.PullModel(ctx, (&pullPrinter{}).print); err != nil {
+			fmt.Printf("\nFailed to pull %q: %v\n", health.Model, err)
+			return false
+		}
+		fmt.Println()
+		return checkProvider(ctx, false)
+	}
+	check(true, "Model %q is available", health.Model)
+
+	if err != nil {
+		check(false, "Model %q cannot embed: %v", health.Model, err)
+		return false
+	}
+	check(true, "Model embeds with %d dimensions", health.Dimension)
+
+	ds, ok := store.(*storage.DiskStore)
+	if !ok {
+		return true
+	}
+	_, stored := ds.Embedding()
+	switch {
+	case stored == 0:
+		check(true, "Store takes the dimension of the first document indexed")
+	case stored == health.Dimension:
+		check(true, "Store holds embeddings of %d dimensions", stored)
+	default:
+		check(false, "Store holds embeddings of %d dimensions, the model returns %d", stored, health.Dimension)
+		return false
+	}
+	return true
+}
+
+func check(ok bool, format string, args ...any) {
+	mark := "✓"
+	if !ok {
+		mark = "✗"
+	}
+	fmt.Printf("%s %s\n", mark, fmt.Sprintf(format, args...))
+}
+
+// confirmPull asks whether to pull the model, unless --pull was passed. Without a terminal to
+// ask on, the model is not pulled.
+func confirmPull(model string) bool {
+	if pullMissing {
+		return true
+	}
+
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		fmt.Printf("  Run `%s doctor --pull` to pull it.\n", config.AppID)
+		return false
+	}
+
+	fmt.Printf("Pull %q now? [y/N] ", model)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// pullPrinter prints the progress of a pull, updating the line of a download in place.
+type pullPrinter struct {
+	status string
+}
+
+func (p *pullPrinter) print(progress embeddings.PullProgress) {
+	if progress.Status != p.status && p.status != "" {
+		fmt.Println()
+	}
+	p.status = progress.Status
+
+	if progress.Total > 0 {
+		fmt.Printf("\r%s: %s / %s (%.0f%%)", progress.Status, formatBytes(progress.Completed), formatBytes(progress.Total),
+			100*float64(progress.Completed)/float64(progress.Total))
+		return
+	}
+	fmt.Printf("\r%s", progress.Status)
+}
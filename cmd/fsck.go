@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/jnaraujo/seekr/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var fsckCmd = &cobra.Command{
+	Use:         "fsck",
+	Short:       "Verify the integrity of the store.",
+	Long:        "Verify the checksum of every record in the store and the dimension of every stored embedding.",
+	Annotations: map[string]string{storeAnnotation: storeNone},
+	Run: func(cmd *cobra.Command, args []string) {
+		storePath, err := storage.DefaultStorePath()
+		if err != nil {
+			fmt.Println("Error getting default store path:", err)
+			return
+		}
+
+		quarantine, _ := cmd.Flags().GetBool("quarantine")
+		lockMode := storage.SharedLock
+		if quarantine {
+			lockMode = storage.ExclusiveLock
+		}
+		lock, err := storage.AcquireLock(storePath, lockMode, lockTimeout)
+		if err != nil {
+			fmt.Printf("failed to check store: %v\n", err)
+			return
+		}
+		defer lock.Release()
+
+		fmt.Printf("Checking store %q...\n", storePath)
+		report, err := storage.Check(storePath)
+		if err != nil {
+			fmt.Printf("failed to check store: %v\n", err)
+			return
+		}
+
+		for _, p := range report.Problems {
+			fmt.Printf("Offset %d (%d bytes): %s\n", p.Offset, p.Size, p.Reason)
+		}
+		if report.End < report.Size {
+			fmt.Printf("Offset %d (%d bytes): incomplete record at the end of the store\n", report.End, report.Size-report.End)
+		}
+		fmt.Printf("Checked %d record(s), %d document(s): %d problem(s) found.\n",
+			report.Records, report.Documents, len(report.Problems))
+
+		if report.OK() {
+			fmt.Println("Store is healthy!")
+			return
+		}
+
+		if !quarantine {
+			fmt.Println("Run with --quarantine to move the bad records out of the store.")
+			return
+		}
+
+		if _, err := storage.Quarantine(storePath); err != nil {
+			fmt.Printf("failed to quarantine bad records: %v\n", err)
+			return
+		}
+		fmt.Printf("Bad records moved to %q\n", storage.QuarantinePath(storePath))
+	},
+}
+
+func init() {
+	fsckCmd.Flags().Bool("quarantine", false, "move bad records out of the store into a quarantine file")
+	rootCmd.AddCommand(fsckCmd)
+}
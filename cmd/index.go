@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -9,23 +8,34 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/jnaraujo/seekr/internal/config"
 	"github.com/jnaraujo/seekr/internal/document"
+	"github.com/jnaraujo/seekr/internal/embeddings"
+	"github.com/jnaraujo/seekr/internal/extract"
 	"github.com/jnaraujo/seekr/internal/id"
 	"github.com/jnaraujo/seekr/internal/storage"
-	"github.com/ledongthuc/pdf"
+	"github.com/jnaraujo/seekr/internal/textsplitter"
 	"github.com/spf13/cobra"
 )
 
 var indexCmd = &cobra.Command{
-	Use:   "index",
-	Short: "Index the document",
-	Args:  cobra.ExactArgs(1),
+	Use:         "index",
+	Short:       "Index the document",
+	Args:        cobra.ExactArgs(1),
+	Annotations: map[string]string{storeAnnotation: storeWrite},
 	Run: func(cmd *cobra.Command, args []string) {
 		inputPath := args[0]
+		err := setupChunking(cmd)
+		if err != nil {
+			fmt.Printf("failed to index document %q: %v\n", inputPath, err)
+			return
+		}
+
 		pathKind, err := storage.CheckPath(inputPath)
 		if err != nil {
 			fmt.Printf("failed to index document %q: %v\n", inputPath, err)
@@ -38,6 +48,16 @@ var indexCmd = &cobra.Command{
 			return
 		}
 
+		if err := checkDimension(cmd.Context()); err != nil {
+			fmt.Printf("Failed to index %q: %v\n", inputPath, err)
+			return
+		}
+
+		// stop is called once the embedding server is down, so that the files left are skipped
+		// instead of each failing after its own retries
+		ctx, stop := context.WithCancel(cmd.Context())
+		defer stop()
+
 		// parallelism for embeddings is not yet supported by Ollama, so I think this should be sufficient for now
 		workers := int(math.Max(2, float64(runtime.NumCPU())))
 		channel := make(chan string, workers)
@@ -46,7 +66,15 @@ var indexCmd = &cobra.Command{
 		for range workers {
 			go func() {
 				for file := range channel {
-					err := indexFile(cmd.Context(), file)
+					if ctx.Err() != nil {
+						wg.Done()
+						continue
+					}
+
+					err := indexFile(ctx, file)
+					if errors.Is(err, embeddings.ErrCircuitOpen) {
+						stop()
+					}
 					if err != nil {
 						fmt.Printf("Failed to index %q: %v\n", file, err)
 					} else {
@@ -65,6 +93,9 @@ var indexCmd = &cobra.Command{
 				fmt.Printf("Failed to index directory %q: %v\n", inputPath, err)
 			} else {
 				for _, file := range files {
+					if ctx.Err() != nil {
+						break
+					}
 					wg.Add(1)
 					channel <- file
 				}
@@ -78,14 +109,177 @@ var indexCmd = &cobra.Command{
 
 		close(channel)
 		wg.Wait()
+		if ctx.Err() != nil && cmd.Context().Err() == nil {
+			fmt.Println("Indexing stopped early: the embedding server is unavailable.")
+			return
+		}
 		fmt.Println("Indexing complete!")
 	},
 }
 
+// chunking holds how chunks are sized and which splitter every file type is split with, set up
+// from the flags when indexing starts.
+var chunking struct {
+	size      int
+	minSize   int
+	overlap   int
+	length    textsplitter.LenFunction
+	splitters map[string]string
+}
+
+// The strategies files can be split with.
+const (
+	recursiveSplitter = "recursive"
+	sentenceSplitter  = "sentence"
+	markdownSplitter  = "markdown"
+	codeSplitter      = "code"
+	semanticSplitter  = "semantic"
+)
+
+var splitterNames = []string{recursiveSplitter, sentenceSplitter, markdownSplitter, codeSplitter, semanticSplitter}
+
+var (
+	chunkUnit    string
+	chunkSize    int
+	chunkMinSize int
+	chunkOverlap int
+	vocabPath    string
+	splitters    map[string]string
+)
+
 func init() {
+	flags := indexCmd.Flags()
+	flags.StringVar(&chunkUnit, "chunk-unit", "runes", "unit chunks are sized in (runes, bytes, tokens)")
+	flags.IntVar(&chunkSize, "chunk-size", config.MaxChunkChars, fmt.Sprintf("maximum size of a chunk (%d when sized in tokens)", config.MaxChunkTokens))
+	flags.IntVar(&chunkMinSize, "chunk-min-size", 0, "minimum size of a chunk split by topic (a quarter of the chunk size by default)")
+	flags.IntVar(&chunkOverlap, "chunk-overlap", config.ChunkOverlapping, fmt.Sprintf("size of the text consecutive chunks share (%d when sized in tokens)", config.ChunkOverlappingTokens))
+	flags.StringVar(&vocabPath, "vocab", "", "WordPiece vocabulary of the model (vocab.txt) to size chunks in tokens with")
+	flags.StringToStringVar(&splitters, "splitter", nil, fmt.Sprintf("splitter to chunk a file type with, such as txt=semantic (%s)", strings.Join(splitterNames, ", ")))
 	rootCmd.AddCommand(indexCmd)
 }
 
+// setupChunking sizes chunks as the flags ask. A vocabulary sizes them in tokens unless another
+// unit is asked for.
+func setupChunking(cmd *cobra.Command) error {
+	flags := cmd.Flags()
+	unit := chunkUnit
+	if vocabPath != "" && !flags.Changed("chunk-unit") {
+		unit = "tokens"
+	}
+
+	size, overlap := chunkSize, chunkOverlap
+	var length textsplitter.LenFunction
+	switch unit {
+	case "runes":
+		length = textsplitter.RuneLength
+	case "bytes":
+		length = textsplitter.ByteLength
+	case "tokens":
+		if vocabPath == "" {
+			return errors.New("sizing chunks in tokens needs the vocabulary of the model, pass it with --vocab")
+		}
+		wp, err := textsplitter.LoadWordPiece(vocabPath)
+		if err != nil {
+			return fmt.Errorf("failed to load vocabulary: %w", err)
+		}
+		length = wp.Count
+		if !flags.Changed("chunk-size") {
+			size = config.MaxChunkTokens
+		}
+		if !flags.Changed("chunk-overlap") {
+			overlap = config.ChunkOverlappingTokens
+		}
+	default:
+		return fmt.Errorf("unknown chunk unit %q (available: runes, bytes, tokens)", unit)
+	}
+
+	if size <= 0 || overlap < 0 || overlap >= size {
+		return fmt.Errorf("chunk overlap must be between 0 and the chunk size, got %d and %d", overlap, size)
+	}
+	minSize := chunkMinSize
+	if !flags.Changed("chunk-min-size") {
+		minSize = size / 4
+	}
+	if minSize < 0 || minSize > size {
+		return fmt.Errorf("chunk minimum size must be between 0 and the chunk size, got %d and %d", minSize, size)
+	}
+
+	byType := make(map[string]string, len(splitters))
+	for ext, name := range splitters {
+		if !slices.Contains(splitterNames, name) {
+			return fmt.Errorf("unknown splitter %q (available: %s)", name, strings.Join(splitterNames, ", "))
+		}
+		byType["."+strings.TrimPrefix(strings.ToLower(ext), ".")] = name
+	}
+
+	chunking.size, chunking.minSize, chunking.overlap, chunking.length = size, minSize, overlap, length
+	chunking.splitters = byType
+	return nil
+}
+
+// splitterFor returns the name of the splitter for the file at path, the one asked for its type
+// with --splitter, or else the one picked by its extension.
+func splitterFor(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if name, ok := chunking.splitters[ext]; ok {
+		return name
+	}
+
+	switch ext {
+	case ".md", ".markdown", ".mdx":
+		return markdownSplitter
+	case ".txt", ".text", ".pdf", ".rst", ".adoc", ".org", ".tex":
+		return sentenceSplitter
+	}
+	if textsplitter.LanguageOf(path) != textsplitter.NoLanguage {
+		return codeSplitter
+	}
+	return recursiveSplitter
+}
+
+// split chunks the content of the file at path with the splitter for its type.
+func split(ctx context.Context, path, content string) ([]textsplitter.Segment, error) {
+	length := textsplitter.WithLengthFunction(chunking.length)
+	var splitter textsplitter.Splitter
+	switch splitterFor(path) {
+	case semanticSplitter:
+		return embeddings.NewSemanticSplitter(embedding, chunking.minSize, chunking.size, length).Split(ctx, content)
+	case markdownSplitter:
+		splitter = textsplitter.NewMarkdownTextSplitter(chunking.size, chunking.overlap, length)
+	case sentenceSplitter:
+		splitter = textsplitter.NewSentenceTextSplitter(chunking.size, chunking.overlap, length)
+	case codeSplitter:
+		// files of other languages are split by their braces
+		splitter = textsplitter.NewCodeTextSplitter(textsplitter.LanguageOf(path), chunking.size, chunking.overlap, length)
+	default:
+		splitter = textsplitter.NewRecursiveCharacterTextSplitter(chunking.size, chunking.overlap, length)
+	}
+	return splitter.Split(content), nil
+}
+
+// checkDimension probes the embedding model before anything is indexed, so that a model whose
+// embeddings do not fit the store fails once instead of once per file.
+func checkDimension(ctx context.Context) error {
+	ds, ok := store.(*storage.DiskStore)
+	if !ok {
+		return nil
+	}
+	_, stored := ds.Embedding()
+	if stored == 0 {
+		return nil
+	}
+
+	dimension, err := embedding.Dimension(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to probe embedding model: %w", err)
+	}
+	if dimension != stored {
+		return fmt.Errorf("%w: the model returns %d dimensions, the store holds %d",
+			storage.ErrEmbeddingMismatch, dimension, stored)
+	}
+	return nil
+}
+
 func indexFile(ctx context.Context, path string) error {
 	if storage.IsHidden(path) {
 		return fmt.Errorf("hidden files are not supported")
@@ -96,29 +290,11 @@ func indexFile(ctx context.Context, path string) error {
 		return errors.New("failed to read document")
 	}
 
-	fileExt := filepath.Ext(path)
-	isPDF := fileExt == ".pdf"
-	contentIsUnsupportedByStorage := !storage.IsFileValid(contentBytes)
-	if !isPDF && contentIsUnsupportedByStorage {
-		return fmt.Errorf("document is not a valid file type")
-	}
-
-	content := ""
-	if isPDF {
-		r, err := pdf.NewReader(bytes.NewReader(contentBytes), int64(len(contentBytes)))
-		if err != nil {
-			return fmt.Errorf("failed to read pdf")
-		}
-		plainReader, err := r.GetPlainText()
-		if err != nil {
-			return fmt.Errorf("failed to read pdf content")
-		}
-		var buf bytes.Buffer
-		buf.ReadFrom(plainReader)
-		content = buf.String()
-	} else {
-		content = string(contentBytes)
+	extracted, err := extract.Extract(path, contentBytes)
+	if err != nil {
+		return fmt.Errorf("failed to extract document text: %w", err)
 	}
+	content := extracted.Text
 
 	if len(content) == 0 {
 		return errors.New("document is empty")
@@ -137,19 +313,24 @@ func indexFile(ctx context.Context, path string) error {
 		}
 	}
 
-	chunks, err := embedding.Embed(ctx, content)
+	segments, err := split(ctx, path, content)
+	if err != nil {
+		return fmt.Errorf("failed to split document: %w", err)
+	}
+	chunks, err := embeddings.EmbedSegments(ctx, embedding, segments)
 	if err != nil {
-		return fmt.Errorf("failed to embed document: %v", err)
+		return fmt.Errorf("failed to embed document: %w", err)
 	}
 
 	doc, err := document.NewDocument(id.HashPath(path), chunks, time.Now(), path)
 	if err != nil {
 		return errors.New("failed to create document ")
 	}
+	doc.Metadata = extracted.Metadata.Fields()
 
 	err = store.Index(ctx, doc)
 	if err != nil {
-		return errors.New("failed to index document")
+		return fmt.Errorf("failed to store document: %w", err)
 	}
 
 	return nil
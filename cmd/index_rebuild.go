@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jnaraujo/seekr/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var indexRebuildCmd = &cobra.Command{
+	Use:         "index-rebuild",
+	Short:       "Rebuild the vector index of the store, switching to the one passed with --index, or retrain the codebooks of a pq store.",
+	Example:     "seekr index-rebuild --index ivf --ivf-nlist 256",
+	Annotations: map[string]string{storeAnnotation: storeWrite, indexAnnotation: indexReplace},
+	Run: func(cmd *cobra.Command, args []string) {
+		rebuilder, ok := store.(storage.IndexRebuilder)
+		if !ok {
+			fmt.Println("The current store does not support vector indexes.")
+			return
+		}
+
+		start := time.Now()
+		err := rebuilder.RebuildIndex(cmd.Context())
+		if errors.Is(err, storage.ErrNoIndex) && indexName == string(storage.BruteForceIndex) {
+			fmt.Println("Vector index dropped, searches compare the query against every chunk.")
+			return
+		}
+		if errors.Is(err, storage.ErrNoIndex) {
+			fmt.Println("No vector index selected, pass --index hnsw or --index ivf.")
+			return
+		}
+		if err != nil {
+			fmt.Printf("failed to rebuild index: %v\n", err)
+			return
+		}
+
+		elapsed := time.Since(start).Round(time.Millisecond)
+		ds, ok := store.(*storage.DiskStore)
+		if !ok {
+			fmt.Printf("Index rebuilt in %s\n", elapsed)
+			return
+		}
+		if ds.Quantization() == storage.ProductQuantization {
+			fmt.Printf("PQ codebooks retrained in %s\n", elapsed)
+			return
+		}
+		fmt.Printf("Index %q rebuilt in %s\n", ds.VectorIndex(), elapsed)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(indexRebuildCmd)
+}
@@ -28,6 +28,10 @@ var listCmd = &cobra.Command{
 
 		maxDigits := countDigits(len(docs))
 		for index, doc := range docs {
+			if title := doc.Metadata["title"]; title != "" {
+				fmt.Printf("(%0*d) %s - %s\n", maxDigits, index, title, doc.Path)
+				continue
+			}
 			fmt.Printf("(%0*d) %s\n", maxDigits, index, doc.Path)
 		}
 	},
@@ -10,10 +10,11 @@ import (
 )
 
 var removeCmd = &cobra.Command{
-	Use:     "remove",
-	Short:   "Removes the document",
-	Aliases: []string{"rm"},
-	Args:    cobra.ExactArgs(1),
+	Use:         "remove",
+	Short:       "Removes the document",
+	Aliases:     []string{"rm"},
+	Args:        cobra.ExactArgs(1),
+	Annotations: map[string]string{storeAnnotation: storeWrite},
 	Run: func(cmd *cobra.Command, args []string) {
 		inputPath := args[0]
 		inputPath, err := filepath.Abs(inputPath)
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -23,6 +24,7 @@ var rootCmd = &cobra.Command{
 
 Learn more at https://github.com/jnaraujo/seekr
 `,
+	PersistentPreRunE: setup,
 	Run: func(cmd *cobra.Command, args []string) {
 		printAscii()
 		fmt.Println("Welcome to SeekR!")
@@ -31,30 +33,207 @@ Learn more at https://github.com/jnaraujo/seekr
 }
 
 func Execute() {
-	embedding = embeddings.NewOllamaProvider(config.DefaultEmbeddingModel, "")
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
+	rootCmd.SilenceErrors = true
+	err := rootCmd.Execute()
+	if store != nil {
+		store.Close()
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// storeAnnotation tells openStore how a command uses the store. Commands without it
+// only read from the store, which is opened under a shared lock.
+const (
+	storeAnnotation = "store"
+	// storeWrite opens the store under an exclusive lock.
+	storeWrite = "write"
+	// storeNone leaves the store closed, for commands that work on the store file themselves.
+	storeNone = "none"
+
+	// indexAnnotation set to indexReplace lets --index replace the vector index the store
+	// was created with, which other commands refuse.
+	indexAnnotation = "index"
+	indexReplace    = "replace"
+)
+
+var (
+	skipCorrupt bool
+	lockTimeout time.Duration
+	indexName   string
+	quantize    string
+	hnswParams  = storage.DefaultHNSWParams()
+	ivfParams   = storage.DefaultIVFParams()
+	pqParams    = storage.DefaultPQParams()
+
+	providerName   string
+	providerConfig embeddings.ProviderConfig
+	retryPolicy    = embeddings.DefaultRetryPolicy()
+	cacheSize      int64
+)
+
+// apiKeyEnv names the environment variable holding the API key of the embedding provider,
+// kept out of the flags so that it does not end up in the shell history.
+const apiKeyEnv = "SEEKR_API_KEY"
+
+func init() {
+	flags := rootCmd.PersistentFlags()
+	flags.StringVar(&providerName, "provider", "ollama", fmt.Sprintf("embedding provider (%s)", strings.Join(embeddings.ProviderNames(), ", ")))
+	flags.StringVar(&providerConfig.Model, "model", config.DefaultEmbeddingModel, "embedding model, remembered by the store (defaults to the one the store was created with)")
+	flags.StringVar(&providerConfig.BaseURL, "provider-url", "", "base URL of the embedding provider API (defaults to the provider's local address)")
+	flags.IntVar(&providerConfig.Dimensions, "dimensions", 0, "embedding dimensions requested from providers that support it (0 keeps the model's)")
+	flags.String("query-prefix", "", "text put before search queries (defaults to the prefix the model was trained with)")
+	flags.String("document-prefix", "", "text put before indexed chunks (defaults to the prefix the model was trained with)")
+	flags.IntVar(&providerConfig.BatchSize, "embed-batch-size", embeddings.DefaultBatchSize, "number of chunks sent to the embedding provider per request")
+	flags.DurationVar(&providerConfig.Timeout, "provider-timeout", embeddings.DefaultTimeout, "how long a request to the embedding provider may take")
+	flags.IntVar(&retryPolicy.MaxRetries, "provider-retries", retryPolicy.MaxRetries, "times a request to the embedding provider is retried after a transient failure")
+	flags.Int64Var(&cacheSize, "cache-size", embeddings.DefaultCacheSize>>20, "MiB the cache of document embeddings may take on disk (0 disables it)")
+	flags.BoolVar(&skipCorrupt, "skip-corrupt", false, "skip corrupt records when loading the store instead of failing")
+	flags.DurationVar(&lockTimeout, "lock-timeout", 10*time.Second, "how long to wait for other seekr processes to release the store")
+	flags.StringVar(&quantize, "quantization", "", "how the store holds embeddings in memory (none, int8, binary, pq), remembered by the store")
+	flags.IntVar(&pqParams.Subspaces, "pq-subspaces", pqParams.Subspaces, "bytes each embedding is compressed to by pq quantization (0 uses one per 8 dimensions)")
+	flags.IntVar(&pqParams.MinChunks, "pq-min-chunks", pqParams.MinChunks, "number of chunks needed to train the pq codebooks")
+	flags.IntVar(&pqParams.Rerank, "pq-rerank", pqParams.Rerank, "documents per result reranked with full embeddings read from disk in pq stores (0 disables)")
+	flags.StringVar(&indexName, "index", "", "vector index used to search the store (brute, hnsw, ivf), remembered by the store")
+	flags.IntVar(&hnswParams.M, "hnsw-m", hnswParams.M, "links per node of the hnsw index")
+	flags.IntVar(&hnswParams.EfConstruction, "hnsw-ef-construction", hnswParams.EfConstruction, "candidate list size used while building the hnsw index")
+	flags.IntVar(&hnswParams.EfSearch, "hnsw-ef-search", hnswParams.EfSearch, "candidate list size used while searching the hnsw index")
+	flags.IntVar(&ivfParams.NList, "ivf-nlist", ivfParams.NList, "number of clusters of the ivf index (0 picks one from the store size)")
+	flags.IntVar(&ivfParams.NProbe, "ivf-nprobe", ivfParams.NProbe, "number of clusters scanned by an ivf search")
+	flags.IntVar(&ivfParams.MinChunks, "ivf-min-chunks", ivfParams.MinChunks, "number of chunks below which the ivf index is not used")
+}
+
+// setup opens the store and creates the embedding provider once the flags are parsed.
+func setup(cmd *cobra.Command, args []string) error {
+	if err := openStore(cmd, args); err != nil {
+		return err
+	}
+
+	flags := cmd.Flags()
+	// the store remembers the model it was created with, so that it does not have to be passed every time
+	if model := requestedModel(cmd); model != "" {
+		providerConfig.Model = model
+	} else if ds, ok := store.(*storage.DiskStore); ok {
+		providerConfig.Model, _ = ds.Embedding()
+	}
+	// as well as their dimension, which providers that pick one themselves have to match
+	if ds, ok := store.(*storage.DiskStore); ok {
+		_, providerConfig.StoreDimension = ds.Embedding()
+	}
+
+	providerConfig.APIKey = os.Getenv(apiKeyEnv)
+	if flags.Changed("query-prefix") || flags.Changed("document-prefix") {
+		prefixes := embeddings.PrefixesFor(providerConfig.Model)
+		if flags.Changed("query-prefix") {
+			prefixes.Query, _ = flags.GetString("query-prefix")
+		}
+		if flags.Changed("document-prefix") {
+			prefixes.Document, _ = flags.GetString("document-prefix")
+		}
+		providerConfig.Prefixes = &prefixes
+	}
+	providerConfig.Retry = &retryPolicy
+	if cacheSize > 0 {
+		cache, err := openCache()
+		if err != nil {
+			return err
+		}
+		providerConfig.Cache = cache
+	}
+	provider, err := embeddings.NewProvider(providerName, providerConfig)
+	if err != nil {
+		return err
+	}
+	embedding = provider
+	return nil
+}
+
+// requestedModel returns the model passed with --model, or the one of a provider that cannot
+// embed with the default model, and "" to keep the one the store was created with.
+func requestedModel(cmd *cobra.Command) string {
+	if cmd.Flags().Changed("model") {
+		return providerConfig.Model
+	}
+	if model := embeddings.DefaultModel(providerName); model != config.DefaultEmbeddingModel {
+		return model
+	}
+	return ""
+}
+
+func openStore(cmd *cobra.Command, args []string) error {
+	usage := cmd.Annotations[storeAnnotation]
+	if usage == storeNone {
+		return nil
+	}
+	cmd.SilenceUsage = true
 
 	storePath, err := storage.DefaultStorePath()
 	if err != nil {
-		fmt.Println("Error getting default store path:", err)
-		return
+		return fmt.Errorf("error getting default store path: %w", err)
 	}
 	if err := storage.EnsureStorePath(storePath); err != nil {
-		fmt.Println("Error ensuring store path:", err)
-		return
+		return fmt.Errorf("error ensuring store path: %w", err)
 	}
 
-	store, err = storage.NewDiskStore(storePath)
-	if err != nil {
-		fmt.Println("Error creating disk store:", err)
-		return
+	lockMode := storage.SharedLock
+	if usage == storeWrite {
+		lockMode = storage.ExclusiveLock
 	}
-	defer store.Close()
 
-	rootCmd.CompletionOptions.DisableDefaultCmd = true
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	opts := []storage.Option{
+		storage.WithLock(lockMode, lockTimeout),
+		storage.WithPQ(pqParams),
+		storage.WithHNSW(hnswParams),
+		storage.WithIVF(ivfParams),
+	}
+	if model := requestedModel(cmd); model != "" || providerConfig.Dimensions > 0 {
+		opts = append(opts, storage.WithEmbedding(model, providerConfig.Dimensions))
+	}
+	if skipCorrupt {
+		opts = append(opts, storage.WithSkipCorrupt())
+	}
+
+	if quantize != "" {
+		q, err := storage.ParseQuantization(quantize)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, storage.WithQuantization(q))
+	}
+
+	if indexName != "" {
+		indexKind, err := storage.ParseIndexKind(indexName)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, storage.WithIndex(indexKind))
+		if cmd.Annotations[indexAnnotation] == indexReplace {
+			opts = append(opts, storage.WithIndexReplacement())
+		}
+	}
+
+	ds, err := storage.NewDiskStore(storePath, opts...)
+	if err != nil {
+		if errors.Is(err, storage.ErrCorrupt) {
+			return fmt.Errorf("error creating disk store: %w\nRun `%s fsck` to inspect it, or pass --skip-corrupt", err, config.AppID)
+		}
+		if errors.Is(err, storage.ErrEmbeddingMismatch) {
+			return fmt.Errorf("error creating disk store: %w\nRun without --model and --dimensions to use the ones it was created with", err)
+		}
+		if errors.Is(err, storage.ErrIndexMismatch) {
+			return fmt.Errorf("error creating disk store: %w\nRun without --index to use the one it was created with, or `%s index-rebuild --index %s` to switch", err, config.AppID, indexName)
+		}
+		return fmt.Errorf("error creating disk store: %w", err)
+	}
+	for _, p := range ds.Problems() {
+		fmt.Printf("Skipped corrupt record at offset %d: %s\n", p.Offset, p.Reason)
 	}
+
+	store = ds
+	return nil
 }
 
 func printAscii() {
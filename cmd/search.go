@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -16,12 +18,12 @@ var searchCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		query := args[0]
 
-		chunks, err := embedding.Embed(cmd.Context(), query)
+		queryEmbedding, err := embedding.EmbedQuery(cmd.Context(), query)
 		if err != nil {
 			fmt.Printf("failed to create embedding: %v\n", err)
 			return
 		}
-		results, err := store.Search(cmd.Context(), chunks[0].Embedding, 5)
+		results, err := store.Search(cmd.Context(), queryEmbedding, 5)
 		if err != nil {
 			fmt.Printf("failed to search documents: %v\n", err)
 			return
@@ -32,10 +34,23 @@ var searchCmd = &cobra.Command{
 			return
 		}
 
-		fmt.Println("(#) %% sim - Path")
+		fmt.Println("(#) %% sim - Path:Lines")
 		fmt.Println("-----------------------------")
 		for index, res := range results {
-			fmt.Printf("(%d) %.2f%% - %s\n", index+1, res.Score*100, res.Document.Path)
+			chunk := res.Document.Chunks[res.BestMatchingChunk]
+			if chunk.Text == "" {
+				// indexed before chunks kept their text
+				fmt.Printf("(%d) %.2f%% - %s\n", index+1, res.Score*100, res.Document.Path)
+				continue
+			}
+			fmt.Printf("(%d) %.2f%% - %s:%s\n", index+1, res.Score*100, res.Document.Path, lineRange(chunk.StartLine, chunk.EndLine))
+			if chunk.Breadcrumb != "" {
+				fmt.Printf("    %s\n", chunk.Breadcrumb)
+			}
+			if chunk.Symbol != "" {
+				fmt.Printf("    %s\n", chunk.Symbol)
+			}
+			fmt.Printf("    %s\n", snippet(chunk.Text, snippetLength))
 		}
 		fmt.Printf("\nFound top %d results.\n", len(results))
 	},
@@ -44,3 +59,23 @@ var searchCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(searchCmd)
 }
+
+// snippetLength is the number of characters of the best matching chunk printed with each result.
+const snippetLength = 160
+
+// snippet collapses the whitespace of text into single spaces and shortens it to at most n runes.
+func snippet(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return strings.TrimSpace(string(runes[:n-1])) + "…"
+}
+
+func lineRange(start, end int) string {
+	if start == end {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d-%d", start, end)
+}
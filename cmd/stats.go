@@ -26,6 +26,16 @@ var statsCmd = &cobra.Command{
 		docs, _ := store.List(cmd.Context())
 		fmt.Printf("Total Documents: %d\n", len(docs))
 
+		if ds, ok := store.(*storage.DiskStore); ok {
+			model, dimension := ds.Embedding()
+			fmt.Printf("Model: %s\n", model)
+			if dimension > 0 {
+				fmt.Printf("Dimension: %d\n", dimension)
+			}
+			fmt.Printf("Quantization: %s\n", ds.Quantization())
+			fmt.Printf("Vector Index: %s\n", ds.VectorIndex())
+		}
+
 		fmt.Println("\nSeekR is running smoothly!")
 	},
 }
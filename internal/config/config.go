@@ -10,9 +10,15 @@ const (
 
 const (
 	// Embedding configuration settings
-	EmbeddingDimension    = 768
-	MaxChunkChars         = 1000
-	ChunkOverlapping      = 200
-	DefaultEmbeddingModel = "hf.co/nomic-ai/nomic-embed-text-v2-moe-gguf"
-	MaxContentChars       = 10_000_000
+	// EmbeddingDimension is the dimension of the default model, held by stores written before
+	// they recorded their own.
+	EmbeddingDimension = 768
+	MaxChunkChars      = 1000
+	ChunkOverlapping   = 200
+	// MaxChunkTokens and ChunkOverlappingTokens size chunks measured in tokens, keeping them well
+	// within the 512 token context of most embedding models.
+	MaxChunkTokens         = 256
+	ChunkOverlappingTokens = 48
+	DefaultEmbeddingModel  = "hf.co/nomic-ai/nomic-embed-text-v2-moe-gguf"
+	MaxContentChars        = 10_000_000
 )
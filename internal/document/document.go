@@ -15,6 +15,8 @@ type Document struct {
 	Chunks    []embeddings.Chunk
 	CreatedAt time.Time
 	Path      string
+	// Metadata holds what the file told about itself, such as its "title", "author" and "pages".
+	Metadata Metadata
 }
 
 func NewDocument(id string, chunks []embeddings.Chunk, createdAt time.Time, path string) (Document, error) {
@@ -0,0 +1,311 @@
+package embeddings
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultCacheSize is the size the embedding cache is allowed to grow to unless configured otherwise.
+const DefaultCacheSize = 512 << 20
+
+// Cache keeps the embeddings of the texts providers embed on disk, so that re-indexing an edited
+// document only embeds the chunks that changed. Entries are content addressed: the key of a text
+// is made of the model, the prefix the text is embedded with and the sha256 of the text, so
+// entries never go stale. Once the cache grows past its size, the least recently used entries are
+// evicted. It is safe for concurrent use, and a nil cache caches nothing.
+type Cache struct {
+	dir     string
+	maxSize int64
+
+	loadOnce sync.Once
+	loadErr  error
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// lru holds the cacheEntry values from the most to the least recently used
+	lru  *list.List
+	size int64
+}
+
+type cacheEntry struct {
+	key  string
+	size int64
+}
+
+// CacheStats describes what the cache holds.
+type CacheStats struct {
+	Entries int
+	Size    int64
+	MaxSize int64
+}
+
+// OpenCache returns the cache kept in dir, which is created if needed. The entries on disk are only
+// read once the cache is first used.
+func OpenCache(dir string, maxSize int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return &Cache{dir: dir, maxSize: maxSize}, nil
+}
+
+// load indexes the entries on disk, ordered by when they were last used.
+func (c *Cache) load() error {
+	c.loadOnce.Do(func() {
+		type found struct {
+			cacheEntry
+			used time.Time
+		}
+		var files []found
+		c.loadErr = filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !isCacheKey(d.Name()) {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			files = append(files, found{cacheEntry{d.Name(), info.Size()}, info.ModTime()})
+			return nil
+		})
+		slices.SortFunc(files, func(a, b found) int {
+			return b.used.Compare(a.used)
+		})
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.entries = make(map[string]*list.Element, len(files))
+		c.lru = list.New()
+		for _, f := range files {
+			c.entries[f.key] = c.lru.PushBack(f.cacheEntry)
+			c.size += f.size
+		}
+	})
+	return c.loadErr
+}
+
+func isCacheKey(name string) bool {
+	_, err := hex.DecodeString(name)
+	return err == nil && len(name) == 2*sha256.Size
+}
+
+func cacheKey(model, prefix, text string) string {
+	sum := sha256.Sum256([]byte(text))
+	h := sha256.New()
+	h.Write([]byte(model))
+	h.Write([]byte{0})
+	h.Write([]byte(prefix))
+	h.Write([]byte{0})
+	h.Write(sum[:])
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// path spreads the entries over 256 directories to keep them small.
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+// get returns the cached embedding of key, if any.
+func (c *Cache) get(key string) ([]float32, bool) {
+	c.mu.Lock()
+	_, ok := c.entries[key]
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(c.path(key))
+	vec, ok := decodeCacheEntry(data)
+	if err != nil || !ok {
+		c.remove(key)
+		return nil, false
+	}
+
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(el)
+	}
+	c.mu.Unlock()
+	// the modification time records the last use for the next runs
+	now := time.Now()
+	os.Chtimes(c.path(key), now, now)
+	return vec, true
+}
+
+// put caches the embedding of key, evicting the least recently used entries past the cache size.
+func (c *Cache) put(key string, vec []float32) error {
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// entries are written whole under a temporary name so that readers never see half of one
+	data := encodeCacheEntry(vec)
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".tmp*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.size -= el.Value.(cacheEntry).size
+		c.lru.Remove(el)
+	}
+	c.entries[key] = c.lru.PushFront(cacheEntry{key, int64(len(data))})
+	c.size += int64(len(data))
+
+	for c.size > c.maxSize && c.lru.Len() > 0 {
+		oldest := c.lru.Back().Value.(cacheEntry)
+		c.removeLocked(oldest.key)
+	}
+	return nil
+}
+
+func (c *Cache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeLocked(key)
+}
+
+func (c *Cache) removeLocked(key string) {
+	el, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	c.size -= el.Value.(cacheEntry).size
+	c.lru.Remove(el)
+	delete(c.entries, key)
+	os.Remove(c.path(key))
+}
+
+// An entry holds the crc32 of the embedding followed by its values, little endian.
+func encodeCacheEntry(vec []float32) []byte {
+	data := make([]byte, 4+4*len(vec))
+	for i, v := range vec {
+		binary.LittleEndian.PutUint32(data[4+4*i:], math.Float32bits(v))
+	}
+	binary.LittleEndian.PutUint32(data, crc32.ChecksumIEEE(data[4:]))
+	return data
+}
+
+func decodeCacheEntry(data []byte) ([]float32, bool) {
+	if len(data) <= 4 || len(data)%4 != 0 || binary.LittleEndian.Uint32(data) != crc32.ChecksumIEEE(data[4:]) {
+		return nil, false
+	}
+	vec := make([]float32, len(data)/4-1)
+	for i := range vec {
+		vec[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4+4*i:]))
+	}
+	return vec, true
+}
+
+// embed returns the embeddings of texts, calling embed with the texts that are not cached and
+// caching what it returns. Failing to use the cache is logged, never returned.
+func (c *Cache) embed(ctx context.Context, model, prefix string, texts []string, embed func(ctx context.Context, texts []string) ([][]float32, error)) ([][]float32, error) {
+	if c == nil {
+		return embed(ctx, texts)
+	}
+	if err := c.load(); err != nil {
+		slog.Warn("failed to load embedding cache", "error", err)
+		return embed(ctx, texts)
+	}
+
+	vecs := make([][]float32, len(texts))
+	keys := make([]string, len(texts))
+	var missing []int
+	for i, text := range texts {
+		keys[i] = cacheKey(model, prefix, text)
+		if vec, ok := c.get(keys[i]); ok {
+			vecs[i] = vec
+		} else {
+			missing = append(missing, i)
+		}
+	}
+	if len(missing) == 0 {
+		return vecs, nil
+	}
+
+	uncached := make([]string, len(missing))
+	for j, i := range missing {
+		uncached[j] = texts[i]
+	}
+	embedded, err := embed(ctx, uncached)
+	if err != nil {
+		return nil, err
+	}
+	if len(embedded) != len(uncached) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(uncached), len(embedded))
+	}
+
+	for j, i := range missing {
+		vecs[i] = embedded[j]
+		if err := c.put(keys[i], embedded[j]); err != nil {
+			slog.Warn("failed to cache embedding", "error", err)
+		}
+	}
+	return vecs, nil
+}
+
+// Stats describes what the cache holds.
+func (c *Cache) Stats() (CacheStats, error) {
+	if err := c.load(); err != nil {
+		return CacheStats{}, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{Entries: len(c.entries), Size: c.size, MaxSize: c.maxSize}, nil
+}
+
+// Clear removes every entry of the cache.
+func (c *Cache) Clear() error {
+	if err := c.load(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	dirs, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	for _, d := range dirs {
+		if err := os.RemoveAll(filepath.Join(c.dir, d.Name())); err != nil {
+			return err
+		}
+	}
+	clear(c.entries)
+	c.lru.Init()
+	c.size = 0
+	return nil
+}
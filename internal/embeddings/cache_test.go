@@ -0,0 +1,113 @@
+package embeddings
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheSkipsEmbeddedChunks(t *testing.T) {
+	var requests [][]string
+	server := newOllamaServer(t, &requests)
+	cache, err := OpenCache(t.TempDir(), DefaultCacheSize)
+	assert.NoError(t, err)
+	p := NewOllamaProvider("nomic-embed-text", server.URL+"/api", WithCache(cache))
+
+	_, err = p.EmbedDocuments(context.Background(), []string{"a", "bb", "ccc"})
+	assert.NoError(t, err)
+
+	// only the edited chunk is sent, with its prefix
+	vecs, err := p.EmbedDocuments(context.Background(), []string{"a", "bbb", "ccc"})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"search_document: a", "search_document: bb", "search_document: ccc"},
+		{"search_document: bbb"},
+	}, requests)
+	assert.Equal(t, fakeEmbedding("search_document: a"), vecs[0])
+	assert.Equal(t, fakeEmbedding("search_document: bbb"), vecs[1])
+
+	// the model and the prefix are part of the key
+	_, err = NewOllamaProvider("other", server.URL+"/api", WithCache(cache)).EmbedDocuments(context.Background(), []string{"a"})
+	assert.NoError(t, err)
+	p = NewOllamaProvider("nomic-embed-text", server.URL+"/api", WithCache(cache), WithPrefixes(PrefixTemplate{}))
+	_, err = p.EmbedDocuments(context.Background(), []string{"a"})
+	assert.NoError(t, err)
+	assert.Len(t, requests, 4)
+
+	stats, err := cache.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, 6, stats.Entries)
+}
+
+func TestCachePersists(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := OpenCache(dir, DefaultCacheSize)
+	assert.NoError(t, err)
+	assert.NoError(t, cache.load())
+	assert.NoError(t, cache.put(cacheKey("model", "", "text"), fakeEmbedding("text")))
+
+	cache, err = OpenCache(dir, DefaultCacheSize)
+	assert.NoError(t, err)
+	assert.NoError(t, cache.load())
+	vec, ok := cache.get(cacheKey("model", "", "text"))
+	assert.True(t, ok)
+	assert.Equal(t, fakeEmbedding("text"), vec)
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	entrySize := int64(len(encodeCacheEntry(fakeEmbedding(""))))
+	cache, err := OpenCache(t.TempDir(), 3*entrySize)
+	assert.NoError(t, err)
+	assert.NoError(t, cache.load())
+
+	for _, text := range []string{"a", "b", "c"} {
+		assert.NoError(t, cache.put(cacheKey("model", "", text), fakeEmbedding(text)))
+	}
+	_, ok := cache.get(cacheKey("model", "", "a"))
+	assert.True(t, ok)
+	assert.NoError(t, cache.put(cacheKey("model", "", "d"), fakeEmbedding("d")))
+
+	for text, kept := range map[string]bool{"a": true, "b": false, "c": true, "d": true} {
+		_, ok := cache.get(cacheKey("model", "", text))
+		assert.Equal(t, kept, ok, text)
+	}
+
+	stats, err := cache.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, CacheStats{Entries: 3, Size: 3 * entrySize, MaxSize: 3 * entrySize}, stats)
+}
+
+func TestCacheCorruptEntry(t *testing.T) {
+	cache, err := OpenCache(t.TempDir(), DefaultCacheSize)
+	assert.NoError(t, err)
+	assert.NoError(t, cache.load())
+
+	key := cacheKey("model", "", "text")
+	assert.NoError(t, cache.put(key, fakeEmbedding("text")))
+	assert.NoError(t, os.WriteFile(cache.path(key), []byte("garbage!"), 0o644))
+
+	_, ok := cache.get(key)
+	assert.False(t, ok)
+	stats, _ := cache.Stats()
+	assert.Equal(t, 0, stats.Entries)
+}
+
+func TestCacheClear(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := OpenCache(dir, DefaultCacheSize)
+	assert.NoError(t, err)
+	assert.NoError(t, cache.load())
+	assert.NoError(t, cache.put(cacheKey("model", "", "text"), fakeEmbedding("text")))
+
+	assert.NoError(t, cache.Clear())
+	stats, err := cache.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, stats.Entries)
+	assert.Equal(t, int64(0), stats.Size)
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
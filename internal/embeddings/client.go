@@ -0,0 +1,76 @@
+package embeddings
+
+import (
+	"net/http"
+	"time"
+)
+
+// clientSettings are the settings of the providers that embed through an HTTP server.
+type clientSettings struct {
+	client    *http.Client
+	batchSize int
+	prefixes  PrefixTemplate
+	retry     RetryPolicy
+	breaker   *CircuitBreaker
+	cache     *Cache
+}
+
+// ClientOption configures a provider that embeds through an HTTP server when it is created.
+type ClientOption func(*clientSettings)
+
+func newClientSettings(model string, opts []ClientOption) clientSettings {
+	s := clientSettings{
+		client:    &http.Client{Timeout: DefaultTimeout},
+		batchSize: DefaultBatchSize,
+		prefixes:  PrefixesFor(model),
+		retry:     DefaultRetryPolicy(),
+		breaker:   DefaultCircuitBreaker(),
+	}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
+}
+
+// WithBatchSize sets how many texts EmbedBatch sends per request.
+func WithBatchSize(size int) ClientOption {
+	return func(s *clientSettings) {
+		s.batchSize = size
+	}
+}
+
+// WithPrefixes replaces the task prefixes picked for the model.
+func WithPrefixes(prefixes PrefixTemplate) ClientOption {
+	return func(s *clientSettings) {
+		s.prefixes = prefixes
+	}
+}
+
+// WithTimeout sets how long a request may take. Every retry of a request gets the full timeout.
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(s *clientSettings) {
+		s.client.Timeout = timeout
+	}
+}
+
+// WithRetry sets how requests that may have failed transiently are retried.
+func WithRetry(policy RetryPolicy) ClientOption {
+	return func(s *clientSettings) {
+		s.retry = policy
+	}
+}
+
+// WithCircuitBreaker replaces the breaker that stops requests once the server looks down.
+// A nil breaker never stops them.
+func WithCircuitBreaker(breaker *CircuitBreaker) ClientOption {
+	return func(s *clientSettings) {
+		s.breaker = breaker
+	}
+}
+
+// WithCache looks the embeddings of documents up in cache before asking the server for them.
+func WithCache(cache *Cache) ClientOption {
+	return func(s *clientSettings) {
+		s.cache = cache
+	}
+}
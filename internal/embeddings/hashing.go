@@ -0,0 +1,82 @@
+package embeddings
+
+import (
+	"context"
+	"hash/fnv"
+	"strings"
+	"unicode"
+
+	"github.com/jnaraujo/seekr/internal/config"
+	"github.com/jnaraujo/seekr/internal/vector"
+)
+
+// HashingModel names the embeddings of the HashingProvider in stores.
+const HashingModel = "seekr-hashing-v1"
+
+// HashingProvider embeds texts without a model or a server, by feature hashing: every word of a
+// text, and every pair of adjacent words, adds one to a bucket picked by its hash, with a sign also
+// taken from the hash so that collisions tend to cancel out. The same text always gets the same
+// embedding and texts sharing words get similar ones, which is enough for tests and machines that
+// cannot run a model, though nothing like a model's grasp of meaning.
+type HashingProvider struct {
+	dimension int
+}
+
+var _ Provider = &HashingProvider{}
+
+// NewHashingProvider returns a provider of embeddings with the given dimension, or with
+// config.EmbeddingDimension when it is 0.
+func NewHashingProvider(dimension int) *HashingProvider {
+	if dimension <= 0 {
+		dimension = config.EmbeddingDimension
+	}
+	return &HashingProvider{dimension: dimension}
+}
+
+func (p *HashingProvider) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return p.Embed(ctx, text)
+}
+
+func (p *HashingProvider) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	return EmbedEach(ctx, texts, p.Embed)
+}
+
+func (p *HashingProvider) Dimension(ctx context.Context) (int, error) {
+	return p.dimension, nil
+}
+
+// Health always finds the provider ready, as it embeds in process.
+func (p *HashingProvider) Health(ctx context.Context) (Health, error) {
+	return Health{Model: HashingModel, Reachable: true, ModelAvailable: true, Dimension: p.dimension}, nil
+}
+
+// Embed returns the normalized hashed n-grams of text. A text without words embeds to zeros.
+func (p *HashingProvider) Embed(ctx context.Context, text string) ([]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	vec := make([]float32, p.dimension)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for i, word := range words {
+		p.add(vec, word)
+		if i > 0 {
+			p.add(vec, words[i-1]+" "+word)
+		}
+	}
+	return vector.Normalize(vec), nil
+}
+
+func (p *HashingProvider) add(vec []float32, feature string) {
+	h := fnv.New64a()
+	h.Write([]byte(feature))
+	sum := h.Sum64()
+
+	if sum>>63 == 1 {
+		vec[sum%uint64(p.dimension)]--
+	} else {
+		vec[sum%uint64(p.dimension)]++
+	}
+}
@@ -0,0 +1,55 @@
+package embeddings
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jnaraujo/seekr/internal/config"
+	"github.com/jnaraujo/seekr/internal/vector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashingProvider(t *testing.T) {
+	ctx := context.Background()
+	p := NewHashingProvider(0)
+
+	dimension, err := p.Dimension(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, config.EmbeddingDimension, dimension)
+
+	query, err := p.EmbedQuery(ctx, "Proxy port?")
+	assert.NoError(t, err)
+	assert.True(t, vector.IsNormalized(query))
+
+	docs, err := p.EmbedDocuments(ctx, []string{"the proxy port", "bread dough", "the PROXY, port"})
+	assert.NoError(t, err)
+	assert.Len(t, docs, 3)
+	assert.Greater(t, vector.CosineSimilarity(query, docs[0]), vector.CosineSimilarity(query, docs[1]))
+	// case and punctuation do not change the words
+	assert.Equal(t, docs[0], docs[2])
+
+	again, _ := NewHashingProvider(0).EmbedQuery(ctx, "Proxy port?")
+	assert.Equal(t, query, again)
+
+	empty, err := p.EmbedQuery(ctx, " ... ")
+	assert.NoError(t, err)
+	assert.Equal(t, make([]float32, dimension), empty)
+}
+
+func TestHashingProviderDimension(t *testing.T) {
+	p, err := NewProvider("hashing", ProviderConfig{Model: "ignored", Dimensions: 64})
+	assert.NoError(t, err)
+	vec, err := p.EmbedQuery(context.Background(), "some words")
+	assert.NoError(t, err)
+	assert.Len(t, vec, 64)
+
+	// the size of the embeddings in the store is kept unless another is asked for
+	p, err = NewProvider("hashing", ProviderConfig{StoreDimension: 32})
+	assert.NoError(t, err)
+	dimension, err := p.Dimension(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 32, dimension)
+
+	assert.Equal(t, HashingModel, DefaultModel("hashing"))
+	assert.Equal(t, config.DefaultEmbeddingModel, DefaultModel("ollama"))
+}
@@ -0,0 +1,52 @@
+package embeddings
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrUnreachable is returned by health checks when the server of a provider does not answer.
+	ErrUnreachable = errors.New("embedding server is unreachable")
+	// ErrModelNotFound is returned by health checks when the server does not have the model.
+	ErrModelNotFound = errors.New("embedding model not found")
+)
+
+// Health describes what a health check of a provider found. Checks stop at the first problem,
+// so the fields after it are left empty.
+type Health struct {
+	// Server is the address of the server checked, empty for providers without one.
+	Server    string
+	Model     string
+	Reachable bool
+	// ModelAvailable tells whether the server lists the model, or, for servers that list none,
+	// whether it embeds with it.
+	ModelAvailable bool
+	// Dimension is the size of the embeddings of the model, found by embedding a probe text.
+	Dimension int
+}
+
+// PullProgress reports how far the download of a model has come.
+type PullProgress struct {
+	Status string
+	// Completed and Total are the bytes of the layer being downloaded, 0 outside of downloads.
+	Completed int64
+	Total     int64
+}
+
+// ModelPuller is implemented by providers able to download a model they do not have.
+type ModelPuller interface {
+	// PullModel downloads the model, reporting progress as it goes.
+	PullModel(ctx context.Context, progress func(PullProgress)) error
+}
+
+// checkDimension completes health with the dimension of the model, embedding a probe text with
+// embed even when the dimension is known, so that the model is checked to embed.
+func checkDimension(ctx context.Context, embed func(context.Context, string) ([]float32, error), health Health) (Health, error) {
+	vec, err := embed(ctx, probeText)
+	if err != nil {
+		return health, err
+	}
+	health.Dimension = len(vec)
+	return health, nil
+}
@@ -0,0 +1,152 @@
+package embeddings
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jnaraujo/seekr/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// newOllamaMux stands in for an Ollama server that has pulled the given models. Pulling a model
+// streams two progress updates before adding it.
+func newOllamaMux(t *testing.T, models ...string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/tags", func(w http.ResponseWriter, r *http.Request) {
+		var tags tagsResponse
+		for _, model := range models {
+			tags.Models = append(tags.Models, ollamaModel{Name: model, Model: model})
+		}
+		json.NewEncoder(w).Encode(tags)
+	})
+	mux.HandleFunc("/api/embed", func(w http.ResponseWriter, r *http.Request) {
+		var req embedRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		resp := embedResponse{Model: req.Model}
+		for _, text := range req.Input {
+			resp.Embedding = append(resp.Embedding, fakeEmbedding(text))
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+	mux.HandleFunc("/api/pull", func(w http.ResponseWriter, r *http.Request) {
+		var req pullRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req.Model == "nope" {
+			fmt.Fprintln(w, `{"status":"pulling manifest"}`)
+			fmt.Fprintln(w, `{"error":"pull model manifest: file does not exist"}`)
+			return
+		}
+		fmt.Fprintln(w, `{"status":"pulling manifest"}`)
+		fmt.Fprintln(w, `{"status":"pulling 970aa74c","digest":"sha256:970aa74c","total":100,"completed":40}`)
+		fmt.Fprintln(w, `{"status":"pulling 970aa74c","digest":"sha256:970aa74c","total":100,"completed":100}`)
+		fmt.Fprintln(w, `{"status":"success"}`)
+		models = append(models, req.Model)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOllamaHealth(t *testing.T) {
+	server := newOllamaMux(t, "nomic-embed-text:latest")
+
+	health, err := NewOllamaProvider("nomic-embed-text", server.URL+"/api").Health(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, Health{
+		Server:         server.URL + "/api",
+		Model:          "nomic-embed-text",
+		Reachable:      true,
+		ModelAvailable: true,
+		Dimension:      config.EmbeddingDimension,
+	}, health)
+
+	health, err = NewOllamaProvider("mxbai-embed-large", server.URL+"/api").Health(context.Background())
+	assert.ErrorIs(t, err, ErrModelNotFound)
+	assert.True(t, health.Reachable)
+	assert.False(t, health.ModelAvailable)
+}
+
+func TestOllamaHealthUnreachable(t *testing.T) {
+	server := newOllamaMux(t)
+	server.Close()
+
+	health, err := NewOllamaProvider("model", server.URL+"/api").Health(context.Background())
+	assert.ErrorIs(t, err, ErrUnreachable)
+	assert.False(t, health.Reachable)
+}
+
+func TestOllamaPullModel(t *testing.T) {
+	server := newOllamaMux(t)
+	p := NewOllamaProvider("all-minilm", server.URL+"/api")
+
+	var progress []PullProgress
+	assert.NoError(t, p.PullModel(context.Background(), func(pp PullProgress) {
+		progress = append(progress, pp)
+	}))
+	assert.Equal(t, []PullProgress{
+		{Status: "pulling manifest"},
+		{Status: "pulling 970aa74c", Completed: 40, Total: 100},
+		{Status: "pulling 970aa74c", Completed: 100, Total: 100},
+		{Status: "success"},
+	}, progress)
+
+	_, err := p.Health(context.Background())
+	assert.NoError(t, err)
+
+	err = NewOllamaProvider("nope", server.URL+"/api").PullModel(context.Background(), nil)
+	assert.ErrorContains(t, err, "file does not exist")
+}
+
+func TestOllamaModelName(t *testing.T) {
+	assert.Equal(t, "all-minilm:latest", ollamaModelName("all-minilm"))
+	assert.Equal(t, "all-minilm:l6-v2", ollamaModelName("all-minilm:l6-v2"))
+	assert.Equal(t, "hf.co/nomic-ai/nomic-embed-text-v2-moe-gguf:latest", ollamaModelName("hf.co/nomic-ai/nomic-embed-text-v2-moe-gguf"))
+	assert.Equal(t, "localhost:5000/model:latest", ollamaModelName("localhost:5000/model"))
+}
+
+func TestOpenAICompatibleHealth(t *testing.T) {
+	var requests []*http.Request
+	var bodies []openAIEmbedRequest
+	embedServer := newOpenAIServer(t, 16, &requests, &bodies)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/models", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(openAIModelsResponse{Data: []openAIModel{{ID: "all-minilm"}, {ID: "missing"}}})
+	})
+	mux.Handle("/v1/embeddings", embedServer.Config.Handler)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	health, err := NewOpenAICompatibleProvider("all-minilm", server.URL+"/v1", "").Health(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, health.ModelAvailable)
+	assert.Equal(t, 16, health.Dimension)
+
+	// the model is embedded with even when its dimension is known
+	sent := len(bodies)
+	health, err = NewOpenAICompatibleProvider("all-minilm", server.URL+"/v1", "").WithDimensions(16).Health(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 16, health.Dimension)
+	assert.Len(t, bodies, sent+1)
+
+	_, err = NewOpenAICompatibleProvider("bge-m3", server.URL+"/v1", "").Health(context.Background())
+	assert.ErrorIs(t, err, ErrModelNotFound)
+
+	// a listed model that fails to embed is available, as with Ollama
+	health, err = NewOpenAICompatibleProvider("missing", server.URL+"/v1", "").Health(context.Background())
+	assert.ErrorContains(t, err, "not found")
+	assert.True(t, health.ModelAvailable)
+
+	// servers that do not list their models are checked with an embedding
+	unlisted := http.NewServeMux()
+	unlisted.Handle("/v1/embeddings", embedServer.Config.Handler)
+	server = httptest.NewServer(unlisted)
+	defer server.Close()
+	health, err = NewOpenAICompatibleProvider("bge-m3", server.URL+"/v1", "").Health(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, health.ModelAvailable)
+}
@@ -6,26 +6,29 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
-	"time"
+	"slices"
+	"strings"
 
 	"github.com/jnaraujo/seekr/internal/config"
-	"github.com/jnaraujo/seekr/internal/textsplitter"
 	"github.com/jnaraujo/seekr/internal/vector"
 )
 
 type OllamaProvider struct {
-	baseURL string
-	model   string
-	client  *http.Client
+	clientSettings
+	baseURL   string
+	model     string
+	dimension modelDimension
 }
 
 var _ Provider = &OllamaProvider{}
+var _ ModelPuller = &OllamaProvider{}
 
 const defaultBaseURLOllama = "http://localhost:11434/api"
 
-func NewOllamaProvider(model, baseURL string) *OllamaProvider {
+func NewOllamaProvider(model, baseURL string, opts ...ClientOption) *OllamaProvider {
 	if baseURL == "" {
 		baseURL = defaultBaseURLOllama
 	}
@@ -34,16 +37,16 @@ func NewOllamaProvider(model, baseURL string) *OllamaProvider {
 	}
 
 	return &OllamaProvider{
-		baseURL: baseURL,
-		model:   model,
-		client:  &http.Client{Timeout: 60 * time.Second},
+		clientSettings: newClientSettings(model, opts),
+		baseURL:        baseURL,
+		model:          model,
 	}
 }
 
 // embedRequest matches the JSON structure sent to the Ollama API.
 type embedRequest struct {
-	Model string `json:"model"`
-	Input string `json:"input"`
+	Model string   `json:"model"`
+	Input []string `json:"input"`
 }
 
 type embedResponse struct {
@@ -54,41 +57,60 @@ type embedResponse struct {
 	PromptEvalCount int         `json:"prompt_eval_count"`
 }
 
-var splitter = textsplitter.NewRecursiveCharacterTextSplitter(config.MaxChunkChars, config.ChunkOverlapping)
+func (p *OllamaProvider) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return p.Embed(ctx, p.prefixes.query(text))
+}
 
-func (p *OllamaProvider) Embed(ctx context.Context, text string) ([]Chunk, error) {
-	blocks := splitter.SplitText(text)
-	chunks := make([]Chunk, 0, len(blocks))
-	for _, block := range blocks {
-		emb, err := p.embedBlock(ctx, block)
-		if err != nil {
-			return nil, err
-		}
-		chunks = append(chunks, Chunk{
-			Embedding: emb,
-		})
+func (p *OllamaProvider) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	return p.cache.embed(ctx, p.model, p.prefixes.Document, texts, func(ctx context.Context, texts []string) ([][]float32, error) {
+		return p.EmbedBatch(ctx, p.prefixes.documents(texts))
+	})
+}
+
+func (p *OllamaProvider) Dimension(ctx context.Context) (int, error) {
+	if dimension := p.dimension.get(); dimension > 0 {
+		return dimension, nil
+	}
+	vec, err := p.Embed(ctx, probeText)
+	if err != nil {
+		return 0, err
 	}
+	return len(vec), nil
+}
+
+// Embed embeds text as it is.
+func (p *OllamaProvider) Embed(ctx context.Context, text string) ([]float32, error) {
+	embeddings, err := p.embedBlocks(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
 
-	return chunks, nil
+// EmbedBatch embeds texts as they are, in batches.
+func (p *OllamaProvider) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return inBatches(ctx, texts, p.batchSize, p.embedBlocks)
 }
 
-func (p *OllamaProvider) embedBlock(ctx context.Context, text string) ([]float32, error) {
+// embedBlocks embeds all texts with a single request.
+func (p *OllamaProvider) embedBlocks(ctx context.Context, texts []string) ([][]float32, error) {
 	reqBody, err := json.Marshal(embedRequest{
 		Model: p.model,
-		Input: text,
+		Input: texts,
 	})
 	if err != nil {
 		return nil, err
 	}
 
 	url := fmt.Sprintf("%s/embed", p.baseURL)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader([]byte(reqBody)))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := p.client.Do(req)
+	resp, err := sendWithRetry(ctx, p.client, p.retry, p.breaker, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(reqBody))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -103,20 +125,136 @@ func (p *OllamaProvider) embedBlock(ctx context.Context, text string) ([]float32
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	embedding := er.Embedding[0]
+	if len(er.Embedding) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(er.Embedding))
+	}
+
+	for i, embedding := range er.Embedding {
+		if len(embedding) == 0 {
+			return nil, errors.New("no embeddings returned")
+		}
+
+		if err := p.dimension.check(len(embedding)); err != nil {
+			return nil, err
+		}
+
+		if !vector.IsNormalized(embedding) {
+			slog.Info("embedding not normalized, normalizing")
+			er.Embedding[i] = vector.Normalize(embedding)
+		}
+	}
+
+	return er.Embedding, nil
+}
+
+type tagsResponse struct {
+	Models []ollamaModel `json:"models"`
+}
+
+type ollamaModel struct {
+	Name  string `json:"name"`
+	Model string `json:"model"`
+}
+
+// Health checks that the server answers, has pulled the model and embeds with it.
+func (p *OllamaProvider) Health(ctx context.Context) (Health, error) {
+	health := Health{Server: p.baseURL, Model: p.model}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/tags", p.baseURL), nil)
+	if err != nil {
+		return health, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return health, fmt.Errorf("%w: %v", ErrUnreachable, err)
+	}
+	defer resp.Body.Close()
+	health.Reachable = true
+
+	if resp.StatusCode != http.StatusOK {
+		return health, fmt.Errorf("ollama API returned status %d", resp.StatusCode)
+	}
+	var tags tagsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return health, fmt.Errorf("failed to decode response: %w", err)
+	}
 
-	if len(embedding) == 0 {
-		return nil, errors.New("no embeddings returned")
+	model := ollamaModelName(p.model)
+	found := slices.ContainsFunc(tags.Models, func(m ollamaModel) bool {
+		return ollamaModelName(m.Name) == model || ollamaModelName(m.Model) == model
+	})
+	if !found {
+		return health, fmt.Errorf("%w: %q has not been pulled", ErrModelNotFound, p.model)
 	}
+	health.ModelAvailable = true
+
+	return checkDimension(ctx, p.Embed, health)
+}
 
-	if len(embedding) != config.EmbeddingDimension {
-		return nil, fmt.Errorf("expected %d dimensions, got %d", config.EmbeddingDimension, len(embedding))
+// ollamaModelName completes a model name with the tag Ollama gives models pulled without one.
+func ollamaModelName(name string) string {
+	if strings.Contains(name[strings.LastIndex(name, "/")+1:], ":") {
+		return name
 	}
+	return name + ":latest"
+}
 
-	if !vector.IsNormalized(embedding) {
-		slog.Info("embedding not normalized, normalizing")
-		embedding = vector.Normalize(embedding)
+type pullRequest struct {
+	Model  string `json:"model"`
+	Stream bool   `json:"stream"`
+}
+
+// pullResponse is one of the lines of JSON the server streams while pulling a model.
+type pullResponse struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest"`
+	Total     int64  `json:"total"`
+	Completed int64  `json:"completed"`
+	Error     string `json:"error"`
+}
+
+// PullModel downloads the model to the server, calling progress with every update it streams.
+func (p *OllamaProvider) PullModel(ctx context.Context, progress func(PullProgress)) error {
+	reqBody, err := json.Marshal(pullRequest{Model: p.model, Stream: true})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/pull", p.baseURL), bytes.NewReader(reqBody))
+	if err != nil {
+		return err
 	}
+	req.Header.Set("Content-Type", "application/json")
 
-	return embedding, nil
+	// downloads take far longer than the timeout of embedding requests
+	client := &http.Client{Transport: p.client.Transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnreachable, err)
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var pr pullResponse
+		if err := decoder.Decode(&pr); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+		if pr.Error != "" {
+			return fmt.Errorf("failed to pull %q: %s", p.model, pr.Error)
+		}
+		if progress != nil {
+			progress(PullProgress{Status: pr.Status, Completed: pr.Completed, Total: pr.Total})
+		}
+		if pr.Status == "success" {
+			return nil
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ollama API returned status %d", resp.StatusCode)
+	}
+	return fmt.Errorf("pull of %q ended before it succeeded", p.model)
 }
@@ -0,0 +1,108 @@
+package embeddings
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jnaraujo/seekr/internal/config"
+	"github.com/jnaraujo/seekr/internal/textsplitter"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeEmbedding returns a unit vector telling texts apart by their length.
+func fakeEmbedding(text string) []float32 {
+	vec := make([]float32, config.EmbeddingDimension)
+	vec[len(text)%config.EmbeddingDimension] = 1
+	return vec
+}
+
+// newOllamaServer stands in for the Ollama API, recording the inputs of every embed request.
+func newOllamaServer(t *testing.T, requests *[][]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/embed", r.URL.Path)
+
+		var req embedRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		*requests = append(*requests, req.Input)
+
+		resp := embedResponse{Model: req.Model}
+		for _, text := range req.Input {
+			resp.Embedding = append(resp.Embedding, fakeEmbedding(text))
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOllamaEmbedBatch(t *testing.T) {
+	var requests [][]string
+	server := newOllamaServer(t, &requests)
+	p := NewOllamaProvider("model", server.URL+"/api", WithBatchSize(2))
+
+	texts := []string{"a", "bb", "ccc", "dddd", "eeeee"}
+	vecs, err := p.EmbedBatch(context.Background(), texts)
+	assert.NoError(t, err)
+
+	assert.Equal(t, [][]string{{"a", "bb"}, {"ccc", "dddd"}, {"eeeee"}}, requests)
+	assert.Len(t, vecs, len(texts))
+	for i, text := range texts {
+		assert.Equal(t, fakeEmbedding(text), vecs[i])
+	}
+}
+
+func TestEmbedSegments(t *testing.T) {
+	var requests [][]string
+	server := newOllamaServer(t, &requests)
+	p := NewOllamaProvider("model", server.URL+"/api")
+
+	segments := []textsplitter.Segment{
+		{Text: "first", Start: 0, End: 5, StartLine: 1, EndLine: 1},
+		{Text: "second\nline", Start: 6, End: 17, StartLine: 2, EndLine: 3, Breadcrumb: "Setup > Proxy", Symbol: "Proxy"},
+	}
+	chunks, err := EmbedSegments(context.Background(), p, segments)
+	assert.NoError(t, err)
+
+	// the breadcrumb is embedded along with the text
+	assert.Equal(t, [][]string{{"first", "Setup > Proxy\n\nsecond\nline"}}, requests)
+	assert.Equal(t, Chunk{
+		Embedding:  fakeEmbedding("Setup > Proxy\n\nsecond\nline"),
+		Text:       "second\nline",
+		Start:      6,
+		End:        17,
+		StartLine:  2,
+		EndLine:    3,
+		Breadcrumb: "Setup > Proxy",
+		Symbol:     "Proxy",
+	}, chunks[1])
+}
+
+func TestEmbedEach(t *testing.T) {
+	var calls []string
+	vecs, err := EmbedEach(context.Background(), []string{"a", "bb", "ccc"}, func(_ context.Context, text string) ([]float32, error) {
+		calls = append(calls, text)
+		return fakeEmbedding(text), nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "bb", "ccc"}, calls)
+	assert.Equal(t, fakeEmbedding("bb"), vecs[1])
+}
+
+func TestOllamaProbesDimension(t *testing.T) {
+	var requests [][]string
+	server := newOllamaServer(t, &requests)
+	p := NewOllamaProvider("model", server.URL+"/api")
+
+	dimension, err := p.Dimension(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, config.EmbeddingDimension, dimension)
+	assert.Equal(t, [][]string{{probeText}}, requests)
+
+	dimension, err = p.Dimension(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, config.EmbeddingDimension, dimension)
+	assert.Len(t, requests, 1)
+}
@@ -0,0 +1,235 @@
+package embeddings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/jnaraujo/seekr/internal/config"
+	"github.com/jnaraujo/seekr/internal/vector"
+)
+
+// OpenAICompatibleProvider embeds texts through the /v1/embeddings endpoint of the OpenAI API,
+// which servers such as llama.cpp, LocalAI and vLLM also expose.
+type OpenAICompatibleProvider struct {
+	clientSettings
+	baseURL    string
+	model      string
+	apiKey     string
+	dimensions int
+	dimension  modelDimension
+}
+
+var _ Provider = &OpenAICompatibleProvider{}
+
+const defaultBaseURLOpenAI = "http://localhost:8080/v1"
+
+// NewOpenAICompatibleProvider returns a provider for the server at baseURL, which includes the /v1 prefix.
+// The apiKey, when not empty, is sent as a bearer token.
+func NewOpenAICompatibleProvider(model, baseURL, apiKey string, opts ...ClientOption) *OpenAICompatibleProvider {
+	if baseURL == "" {
+		baseURL = defaultBaseURLOpenAI
+	}
+	if model == "" {
+		model = config.DefaultEmbeddingModel
+	}
+
+	return &OpenAICompatibleProvider{
+		clientSettings: newClientSettings(model, opts),
+		baseURL:        baseURL,
+		model:          model,
+		apiKey:         apiKey,
+	}
+}
+
+// WithDimensions asks models that support it to return embeddings with the given number of dimensions.
+func (p *OpenAICompatibleProvider) WithDimensions(dimensions int) *OpenAICompatibleProvider {
+	p.dimensions = dimensions
+	p.dimension.set(dimensions)
+	return p
+}
+
+type openAIEmbedRequest struct {
+	Model          string   `json:"model"`
+	Input          []string `json:"input"`
+	EncodingFormat string   `json:"encoding_format"`
+	Dimensions     int      `json:"dimensions,omitempty"`
+}
+
+type openAIEmbedResponse struct {
+	Model string `json:"model"`
+	Data  []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+type openAIErrorResponse struct {
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *OpenAICompatibleProvider) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return p.Embed(ctx, p.prefixes.query(text))
+}
+
+func (p *OpenAICompatibleProvider) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	// models that support it return embeddings of another size when asked to
+	model := p.model
+	if p.dimensions > 0 {
+		model = fmt.Sprintf("%s@%d", p.model, p.dimensions)
+	}
+	return p.cache.embed(ctx, model, p.prefixes.Document, texts, func(ctx context.Context, texts []string) ([][]float32, error) {
+		return p.EmbedBatch(ctx, p.prefixes.documents(texts))
+	})
+}
+
+func (p *OpenAICompatibleProvider) Dimension(ctx context.Context) (int, error) {
+	if dimension := p.dimension.get(); dimension > 0 {
+		return dimension, nil
+	}
+	vec, err := p.Embed(ctx, probeText)
+	if err != nil {
+		return 0, err
+	}
+	return len(vec), nil
+}
+
+// Embed embeds text as it is.
+func (p *OpenAICompatibleProvider) Embed(ctx context.Context, text string) ([]float32, error) {
+	embeddings, err := p.embedBlocks(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// EmbedBatch embeds texts as they are, in batches.
+func (p *OpenAICompatibleProvider) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return inBatches(ctx, texts, p.batchSize, p.embedBlocks)
+}
+
+// embedBlocks embeds all texts with a single request.
+func (p *OpenAICompatibleProvider) embedBlocks(ctx context.Context, texts []string) ([][]float32, error) {
+	reqBody, err := json.Marshal(openAIEmbedRequest{
+		Model:          p.model,
+		Input:          texts,
+		EncodingFormat: "float",
+		Dimensions:     p.dimensions,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/embeddings", p.baseURL)
+	resp, err := sendWithRetry(ctx, p.client, p.retry, p.breaker, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(reqBody))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if p.apiKey != "" {
+			req.Header.Set("Authorization", "Bearer "+p.apiKey)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var er openAIErrorResponse
+		if json.NewDecoder(resp.Body).Decode(&er) == nil && er.Error.Message != "" {
+			return nil, fmt.Errorf("embeddings API returned status %d: %s", resp.StatusCode, er.Error.Message)
+		}
+		return nil, fmt.Errorf("embeddings API returned status %d", resp.StatusCode)
+	}
+
+	var er openAIEmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&er); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if len(er.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(er.Data))
+	}
+
+	embeddings := make([][]float32, len(texts))
+	for _, data := range er.Data {
+		if data.Index < 0 || data.Index >= len(texts) || embeddings[data.Index] != nil {
+			return nil, fmt.Errorf("unexpected embedding index %d", data.Index)
+		}
+
+		embedding := data.Embedding
+		if len(embedding) == 0 {
+			return nil, errors.New("no embeddings returned")
+		}
+
+		if err := p.dimension.check(len(embedding)); err != nil {
+			return nil, err
+		}
+
+		if !vector.IsNormalized(embedding) {
+			slog.Info("embedding not normalized, normalizing")
+			embedding = vector.Normalize(embedding)
+		}
+		embeddings[data.Index] = embedding
+	}
+
+	return embeddings, nil
+}
+
+type openAIModelsResponse struct {
+	Data []openAIModel `json:"data"`
+}
+
+type openAIModel struct {
+	ID string `json:"id"`
+}
+
+// Health checks that the server answers, lists the model and embeds with it. Servers that do not
+// list their models are only asked for an embedding.
+func (p *OpenAICompatibleProvider) Health(ctx context.Context) (Health, error) {
+	health := Health{Server: p.baseURL, Model: p.model}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/models", p.baseURL), nil)
+	if err != nil {
+		return health, err
+	}
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return health, fmt.Errorf("%w: %v", ErrUnreachable, err)
+	}
+	defer resp.Body.Close()
+	health.Reachable = true
+
+	var models openAIModelsResponse
+	listed := resp.StatusCode == http.StatusOK && json.NewDecoder(resp.Body).Decode(&models) == nil && len(models.Data) > 0
+	if listed {
+		found := slices.ContainsFunc(models.Data, func(m openAIModel) bool {
+			return m.ID == p.model
+		})
+		if !found {
+			return health, fmt.Errorf("%w: the server does not list %q", ErrModelNotFound, p.model)
+		}
+		health.ModelAvailable = true
+	}
+
+	health, err = checkDimension(ctx, p.Embed, health)
+	if err != nil {
+		return health, err
+	}
+	// without a listing, embedding with the model is what shows the server has it
+	health.ModelAvailable = true
+	return health, nil
+}
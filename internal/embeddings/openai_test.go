@@ -0,0 +1,123 @@
+package embeddings
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newOpenAIServer stands in for an OpenAI-compatible server. It answers with the embeddings in
+// reverse order, as the API allows, and records every request it gets.
+func newOpenAIServer(t *testing.T, dimension int, requests *[]*http.Request, bodies *[]openAIEmbedRequest) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/embeddings", r.URL.Path)
+
+		var req openAIEmbedRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		*requests = append(*requests, r)
+		*bodies = append(*bodies, req)
+
+		if req.Model == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": {"message": "model \"missing\" not found"}}`))
+			return
+		}
+
+		var resp openAIEmbedResponse
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			vec := make([]float32, dimension)
+			vec[len(req.Input[i])%dimension] = 2
+			resp.Data = append(resp.Data, struct {
+				Index     int       `json:"index"`
+				Embedding []float32 `json:"embedding"`
+			}{Index: i, Embedding: vec})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOpenAICompatibleEmbedBatch(t *testing.T) {
+	var requests []*http.Request
+	var bodies []openAIEmbedRequest
+	server := newOpenAIServer(t, 16, &requests, &bodies)
+
+	p := NewOpenAICompatibleProvider("all-minilm", server.URL+"/v1", "secret", WithBatchSize(2)).
+		WithDimensions(16)
+
+	vecs, err := p.EmbedBatch(context.Background(), []string{"a", "bb", "ccc"})
+	assert.NoError(t, err)
+
+	assert.Len(t, requests, 2)
+	assert.Equal(t, "Bearer secret", requests[0].Header.Get("Authorization"))
+	assert.Equal(t, openAIEmbedRequest{Model: "all-minilm", Input: []string{"a", "bb"}, EncodingFormat: "float", Dimensions: 16}, bodies[0])
+	assert.Equal(t, []string{"ccc"}, bodies[1].Input)
+
+	assert.Len(t, vecs, 3)
+	for i, vec := range vecs {
+		// embeddings are put back in input order and normalized
+		assert.Equal(t, float32(1), vec[i+1])
+	}
+}
+
+func TestOpenAICompatibleWithoutAPIKey(t *testing.T) {
+	var requests []*http.Request
+	var bodies []openAIEmbedRequest
+	server := newOpenAIServer(t, 16, &requests, &bodies)
+
+	p := NewOpenAICompatibleProvider("model", server.URL+"/v1", "").WithDimensions(16)
+	_, err := p.Embed(context.Background(), "query")
+	assert.NoError(t, err)
+	assert.Empty(t, requests[0].Header.Get("Authorization"))
+}
+
+func TestOpenAICompatibleErrors(t *testing.T) {
+	var requests []*http.Request
+	var bodies []openAIEmbedRequest
+	server := newOpenAIServer(t, 16, &requests, &bodies)
+
+	_, err := NewOpenAICompatibleProvider("missing", server.URL+"/v1", "").Embed(context.Background(), "query")
+	assert.ErrorContains(t, err, `model "missing" not found`)
+
+	// the server returns 16 dimensions where 32 were asked for
+	_, err = NewOpenAICompatibleProvider("model", server.URL+"/v1", "").WithDimensions(32).Embed(context.Background(), "query")
+	assert.ErrorContains(t, err, "expected 32 dimensions, got 16")
+
+	// without dimensions asked for, the first embedding sets them
+	var dimensions []int
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dimension := 8 + 8*len(dimensions)
+		dimensions = append(dimensions, dimension)
+		var resp openAIEmbedResponse
+		resp.Data = append(resp.Data, struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		}{Embedding: make([]float32, dimension)})
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+	p := NewOpenAICompatibleProvider("model", server.URL+"/v1", "")
+	dimension, err := p.Dimension(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 8, dimension)
+	_, err = p.Embed(context.Background(), "query")
+	assert.ErrorContains(t, err, "expected 8 dimensions, got 16")
+}
+
+func TestNewProvider(t *testing.T) {
+	p, err := NewProvider("openai", ProviderConfig{Model: "model", Dimensions: 16})
+	assert.NoError(t, err)
+	assert.IsType(t, &OpenAICompatibleProvider{}, p)
+
+	p, err = NewProvider("ollama", ProviderConfig{})
+	assert.NoError(t, err)
+	assert.IsType(t, &OllamaProvider{}, p)
+
+	_, err = NewProvider("nope", ProviderConfig{})
+	assert.ErrorContains(t, err, "hashing, ollama, openai")
+}
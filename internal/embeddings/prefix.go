@@ -0,0 +1,52 @@
+package embeddings
+
+import "strings"
+
+// PrefixTemplate holds the task prefixes an instruction-tuned model expects before the texts it
+// embeds: one for search queries and one for the documents searched.
+type PrefixTemplate struct {
+	Query    string
+	Document string
+}
+
+func (t PrefixTemplate) query(text string) string {
+	return t.Query + text
+}
+
+func (t PrefixTemplate) documents(texts []string) []string {
+	if t.Document == "" {
+		return texts
+	}
+	prefixed := make([]string, len(texts))
+	for i, text := range texts {
+		prefixed[i] = t.Document + text
+	}
+	return prefixed
+}
+
+const retrievalInstruction = "Represent this sentence for searching relevant passages: "
+
+// modelPrefixes lists the prefixes of known model families, matched in order against the model name.
+var modelPrefixes = []struct {
+	match    string
+	template PrefixTemplate
+}{
+	{"nomic-embed-text", PrefixTemplate{Query: "search_query: ", Document: "search_document: "}},
+	{"mxbai-embed-large", PrefixTemplate{Query: retrievalInstruction}},
+	{"snowflake-arctic-embed", PrefixTemplate{Query: retrievalInstruction}},
+	// bge-m3 was trained without instructions, unlike the English bge models
+	{"bge-m3", PrefixTemplate{}},
+	{"bge-", PrefixTemplate{Query: retrievalInstruction}},
+	{"e5-", PrefixTemplate{Query: "query: ", Document: "passage: "}},
+}
+
+// PrefixesFor returns the prefixes the model expects, or none if the model is not known to use any.
+func PrefixesFor(model string) PrefixTemplate {
+	model = strings.ToLower(model)
+	for _, m := range modelPrefixes {
+		if strings.Contains(model, m.match) {
+			return m.template
+		}
+	}
+	return PrefixTemplate{}
+}
@@ -0,0 +1,55 @@
+package embeddings
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/jnaraujo/seekr/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefixesFor(t *testing.T) {
+	nomic := PrefixTemplate{Query: "search_query: ", Document: "search_document: "}
+	assert.Equal(t, nomic, PrefixesFor(config.DefaultEmbeddingModel))
+	assert.Equal(t, nomic, PrefixesFor("nomic-embed-text:latest"))
+	assert.Equal(t, PrefixTemplate{Query: retrievalInstruction}, PrefixesFor("BAAI/bge-large-en-v1.5"))
+	assert.Equal(t, PrefixTemplate{}, PrefixesFor("bge-m3"))
+	assert.Equal(t, PrefixTemplate{Query: "query: ", Document: "passage: "}, PrefixesFor("intfloat/multilingual-e5-large"))
+	assert.Equal(t, PrefixTemplate{}, PrefixesFor("all-minilm"))
+}
+
+func TestOllamaPrefixes(t *testing.T) {
+	var requests [][]string
+	server := newOllamaServer(t, &requests)
+	p := NewOllamaProvider("nomic-embed-text", server.URL+"/api")
+
+	_, err := p.EmbedQuery(context.Background(), "proxy")
+	assert.NoError(t, err)
+	_, err = p.EmbedDocuments(context.Background(), []string{"a", "b"})
+	assert.NoError(t, err)
+	_, err = p.Embed(context.Background(), "raw")
+	assert.NoError(t, err)
+
+	assert.Equal(t, [][]string{
+		{"search_query: proxy"},
+		{"search_document: a", "search_document: b"},
+		{"raw"},
+	}, requests)
+}
+
+func TestOpenAICompatibleWithPrefixes(t *testing.T) {
+	var requests []*http.Request
+	var bodies []openAIEmbedRequest
+	server := newOpenAIServer(t, 16, &requests, &bodies)
+	p := NewOpenAICompatibleProvider("nomic-embed-text", server.URL+"/v1", "", WithPrefixes(PrefixTemplate{Query: "q: "})).
+		WithDimensions(16)
+
+	_, err := p.EmbedQuery(context.Background(), "proxy")
+	assert.NoError(t, err)
+	_, err = p.EmbedDocuments(context.Background(), []string{"a"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"q: proxy"}, bodies[0].Input)
+	assert.Equal(t, []string{"a"}, bodies[1].Input)
+}
@@ -1,11 +1,144 @@
 package embeddings
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/jnaraujo/seekr/internal/textsplitter"
+)
 
 type Chunk struct {
 	Embedding []float32
+	// Text is the part of the document the chunk was embedded from. It lies between the byte
+	// offsets Start and End of the document, on lines StartLine to EndLine (1-based).
+	// Documents indexed before chunks kept their text have it empty and the positions zero.
+	Text      string
+	Start     int
+	End       int
+	StartLine int
+	EndLine   int
+	// Breadcrumb is the path of headings the chunk lies under, embedded along with its text.
+	Breadcrumb string
+	// Symbol is the name of the declaration the chunk holds, for chunks of source code.
+	Symbol string
 }
 
 type Provider interface {
-	Embed(ctx context.Context, text string) ([]Chunk, error)
+	// EmbedQuery embeds a search query, with the query prefix of the model.
+	EmbedQuery(ctx context.Context, text string) ([]float32, error)
+	// EmbedDocuments embeds the chunks of a document in batches, with the document prefix of
+	// the model, returning their embeddings in the same order.
+	EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error)
+	// Dimension returns the size of the embeddings of the model, probing the model if nothing
+	// was embedded yet.
+	Dimension(ctx context.Context) (int, error)
+	// Health checks that the provider can embed, returning what it found along with the first
+	// problem, which wraps ErrUnreachable or ErrModelNotFound when it is one of those.
+	Health(ctx context.Context) (Health, error)
+}
+
+// probeText is embedded to learn the dimension of a model.
+const probeText = "dimension probe"
+
+// modelDimension learns the dimension of a model from the first embedding it returns and holds
+// the later ones to it.
+type modelDimension struct {
+	mu        sync.Mutex
+	dimension int
+}
+
+func (d *modelDimension) get() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.dimension
+}
+
+func (d *modelDimension) set(dimension int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dimension = dimension
+}
+
+func (d *modelDimension) check(dimension int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.dimension == 0 {
+		d.dimension = dimension
+		return nil
+	}
+	if dimension != d.dimension {
+		return fmt.Errorf("expected %d dimensions, got %d", d.dimension, dimension)
+	}
+	return nil
+}
+
+// DefaultBatchSize is the number of texts providers send per request unless configured otherwise.
+const DefaultBatchSize = 32
+
+// EmbedSegments embeds the segments of a document in batches and returns them as chunks.
+func EmbedSegments(ctx context.Context, p Provider, segments []textsplitter.Segment) ([]Chunk, error) {
+	texts := make([]string, len(segments))
+	for i, seg := range segments {
+		texts[i] = seg.Text
+		if seg.Breadcrumb != "" {
+			texts[i] = seg.Breadcrumb + "\n\n" + seg.Text
+		}
+	}
+
+	vecs, err := p.EmbedDocuments(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	if len(vecs) != len(segments) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(segments), len(vecs))
+	}
+
+	chunks := make([]Chunk, len(segments))
+	for i, seg := range segments {
+		chunks[i] = Chunk{
+			Embedding:  vecs[i],
+			Text:       seg.Text,
+			Start:      seg.Start,
+			End:        seg.End,
+			StartLine:  seg.StartLine,
+			EndLine:    seg.EndLine,
+			Breadcrumb: seg.Breadcrumb,
+			Symbol:     seg.Symbol,
+		}
+	}
+	return chunks, nil
+}
+
+// inBatches calls embed with consecutive batches of at most size texts and concatenates the results.
+func inBatches(ctx context.Context, texts []string, size int, embed func(ctx context.Context, batch []string) ([][]float32, error)) ([][]float32, error) {
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+
+	vecs := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += size {
+		batch := texts[start:min(start+size, len(texts))]
+		embedded, err := embed(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		if len(embedded) != len(batch) {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", len(batch), len(embedded))
+		}
+		vecs = append(vecs, embedded...)
+	}
+	return vecs, nil
+}
+
+// EmbedEach embeds a batch of texts for providers whose backend only accepts a single input,
+// embedding the texts one request at a time.
+func EmbedEach(ctx context.Context, texts []string, embed func(ctx context.Context, text string) ([]float32, error)) ([][]float32, error) {
+	return inBatches(ctx, texts, 1, func(ctx context.Context, batch []string) ([][]float32, error) {
+		vec, err := embed(ctx, batch[0])
+		if err != nil {
+			return nil, err
+		}
+		return [][]float32{vec}, nil
+	})
 }
@@ -0,0 +1,95 @@
+package embeddings
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jnaraujo/seekr/internal/config"
+)
+
+// ProviderConfig holds the settings a provider is created with. Empty fields take the
+// defaults of the provider.
+type ProviderConfig struct {
+	Model   string
+	BaseURL string
+	// APIKey is sent as a bearer token by providers that support authentication.
+	APIKey string
+	// Dimensions asks the model for embeddings of that size, when the provider supports it.
+	Dimensions int
+	// StoreDimension is the size of the embeddings in the store, taken by providers that pick the
+	// size of their embeddings themselves when Dimensions is not set.
+	StoreDimension int
+	BatchSize      int
+	// Prefixes replaces the task prefixes picked for the model when set.
+	Prefixes *PrefixTemplate
+	Timeout  time.Duration
+	Retry    *RetryPolicy
+	// Cache holds the embeddings of documents already embedded, when set.
+	Cache *Cache
+}
+
+var providers = map[string]func(cfg ProviderConfig) Provider{
+	"ollama": func(cfg ProviderConfig) Provider {
+		return NewOllamaProvider(cfg.Model, cfg.BaseURL, cfg.clientOptions()...)
+	},
+	"openai": func(cfg ProviderConfig) Provider {
+		return NewOpenAICompatibleProvider(cfg.Model, cfg.BaseURL, cfg.APIKey, cfg.clientOptions()...).
+			WithDimensions(cfg.Dimensions)
+	},
+	// hashing needs no server, so it takes none of the settings but the dimension
+	"hashing": func(cfg ProviderConfig) Provider {
+		if cfg.Dimensions == 0 {
+			return NewHashingProvider(cfg.StoreDimension)
+		}
+		return NewHashingProvider(cfg.Dimensions)
+	},
+}
+
+// clientOptions returns the settings of cfg taken by the providers that embed through an HTTP server.
+func (cfg ProviderConfig) clientOptions() []ClientOption {
+	opts := []ClientOption{WithBatchSize(cfg.BatchSize), WithCache(cfg.Cache)}
+	if cfg.Prefixes != nil {
+		opts = append(opts, WithPrefixes(*cfg.Prefixes))
+	}
+	if cfg.Timeout > 0 {
+		opts = append(opts, WithTimeout(cfg.Timeout))
+	}
+	if cfg.Retry != nil {
+		opts = append(opts, WithRetry(*cfg.Retry))
+	}
+	return opts
+}
+
+// defaultModels holds the models of providers that do not default to config.DefaultEmbeddingModel.
+var defaultModels = map[string]string{
+	"hashing": HashingModel,
+}
+
+// NewProvider creates the provider registered under name.
+func NewProvider(name string, cfg ProviderConfig) (Provider, error) {
+	newProvider, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown embedding provider %q (available: %s)", name, strings.Join(ProviderNames(), ", "))
+	}
+	return newProvider(cfg), nil
+}
+
+// ProviderNames returns the names providers can be created with, sorted.
+func ProviderNames() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// DefaultModel returns the model the provider registered under name embeds with unless told otherwise.
+func DefaultModel(name string) string {
+	if model, ok := defaultModels[name]; ok {
+		return model
+	}
+	return config.DefaultEmbeddingModel
+}
@@ -0,0 +1,189 @@
+package embeddings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout is how long providers wait for a single request unless configured otherwise.
+// Loading a large model can take a while, so it is generous.
+const DefaultTimeout = 60 * time.Second
+
+// RetryPolicy controls how requests that failed in a way that may be transient are retried.
+type RetryPolicy struct {
+	// MaxRetries is the number of times a request is retried, 0 disables retries.
+	MaxRetries int
+	// BaseDelay is the wait before the first retry, doubled on every retry up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: 3,
+		BaseDelay:  500 * time.Millisecond,
+		MaxDelay:   30 * time.Second,
+	}
+}
+
+// backoff returns how long to wait before the retry that follows the given 0-based attempt. Half
+// of the delay is random, so that workers failing together do not retry together.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.MaxDelay
+	if attempt < 32 && p.BaseDelay<<attempt < p.MaxDelay {
+		delay = p.BaseDelay << attempt
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// ErrCircuitOpen is returned without contacting the server once too many requests to it in a row
+// have failed, as the server is most likely down.
+var ErrCircuitOpen = errors.New("embedding server is unavailable")
+
+// CircuitBreaker stops requests to a server after threshold consecutive failures. Once cooldown has
+// passed, requests are let through again, and the breaker opens again on the next failure unless
+// one succeeds. It is safe for concurrent use, and a nil breaker never opens.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// DefaultCircuitBreaker returns the breaker providers are created with.
+func DefaultCircuitBreaker() *CircuitBreaker {
+	return NewCircuitBreaker(5, 30*time.Second)
+}
+
+// Open reports whether requests are currently being refused.
+func (b *CircuitBreaker) Open() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return time.Now().Before(b.openUntil)
+}
+
+func (b *CircuitBreaker) success() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.openUntil = time.Time{}
+}
+
+func (b *CircuitBreaker) failure() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
+
+// retryableStatus reports whether a response with the status may succeed if the request is sent again.
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusInternalServerError,
+		http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter parses the Retry-After header of resp, given either in seconds or as a date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+// sendWithRetry sends the request made by newRequest, retrying network errors and retryable
+// statuses as the policy allows. The wait asked for by a Retry-After header is honored up to
+// the maximum delay of the policy. Once retries run out, the last response is returned for the
+// caller to report its status. Every failed attempt counts towards opening the breaker.
+func sendWithRetry(ctx context.Context, client *http.Client, policy RetryPolicy, breaker *CircuitBreaker, newRequest func(ctx context.Context) (*http.Request, error)) (*http.Response, error) {
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		if breaker.Open() {
+			if lastErr != nil {
+				return nil, fmt.Errorf("%w: %v", ErrCircuitOpen, lastErr)
+			}
+			return nil, ErrCircuitOpen
+		}
+
+		req, err := newRequest(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req)
+		if err == nil && !retryableStatus(resp.StatusCode) {
+			breaker.success()
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			if resp != nil {
+				resp.Body.Close()
+			}
+			return nil, ctx.Err()
+		}
+
+		breaker.failure()
+		if attempt >= policy.MaxRetries {
+			return resp, err
+		}
+
+		delay := policy.backoff(attempt)
+		if resp != nil {
+			if after, ok := retryAfter(resp); ok {
+				delay = min(after, policy.MaxDelay)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			lastErr = fmt.Errorf("server returned status %d", resp.StatusCode)
+		} else {
+			lastErr = err
+		}
+		slog.Debug("retrying embedding request", "attempt", attempt+1, "delay", delay, "error", lastErr)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package embeddings
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var fastRetry = RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+// newFlakyServer answers the first failures requests with status, then embeds like newOllamaServer.
+func newFlakyServer(t *testing.T, failures, status int, header http.Header, requests *int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		if *requests <= failures {
+			for key, values := range header {
+				w.Header()[key] = values
+			}
+			w.WriteHeader(status)
+			return
+		}
+
+		var req embedRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		resp := embedResponse{Model: req.Model}
+		for _, text := range req.Input {
+			resp.Embedding = append(resp.Embedding, fakeEmbedding(text))
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRetryTransientErrors(t *testing.T) {
+	var requests int
+	server := newFlakyServer(t, 2, http.StatusServiceUnavailable, nil, &requests)
+	p := NewOllamaProvider("model", server.URL+"/api", WithRetry(fastRetry))
+
+	vec, err := p.Embed(context.Background(), "text")
+	assert.NoError(t, err)
+	assert.Equal(t, fakeEmbedding("text"), vec)
+	assert.Equal(t, 3, requests)
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	var requests int
+	server := newFlakyServer(t, 10, http.StatusInternalServerError, nil, &requests)
+	p := NewOllamaProvider("model", server.URL+"/api", WithRetry(fastRetry), WithCircuitBreaker(nil))
+
+	_, err := p.Embed(context.Background(), "text")
+	assert.ErrorContains(t, err, "status 500")
+	assert.Equal(t, 4, requests)
+}
+
+func TestNoRetryOnClientErrors(t *testing.T) {
+	var requests int
+	server := newFlakyServer(t, 1, http.StatusBadRequest, nil, &requests)
+	p := NewOllamaProvider("model", server.URL+"/api", WithRetry(fastRetry))
+
+	_, err := p.Embed(context.Background(), "text")
+	assert.ErrorContains(t, err, "status 400")
+	assert.Equal(t, 1, requests)
+}
+
+func TestRetryAfter(t *testing.T) {
+	resp := &http.Response{Header: http.Header{"Retry-After": {"2"}}}
+	after, ok := retryAfter(resp)
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Second, after)
+
+	resp.Header.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	after, ok = retryAfter(resp)
+	assert.True(t, ok)
+	assert.InDelta(t, time.Hour, after, float64(2*time.Second))
+
+	resp.Header.Set("Retry-After", "soon")
+	_, ok = retryAfter(resp)
+	assert.False(t, ok)
+
+	// a Retry-After beyond the maximum delay of the policy is cut short
+	var requests int
+	server := newFlakyServer(t, 1, http.StatusTooManyRequests, http.Header{"Retry-After": {"3600"}}, &requests)
+	p := NewOllamaProvider("model", server.URL+"/api", WithRetry(fastRetry))
+	_, err := p.Embed(context.Background(), "text")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, requests)
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt, want := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		delay := policy.backoff(attempt)
+		assert.GreaterOrEqual(t, delay, want/2)
+		assert.LessOrEqual(t, delay, want)
+	}
+	assert.LessOrEqual(t, policy.backoff(100), time.Second)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var requests int
+	server := newFlakyServer(t, 100, http.StatusBadGateway, nil, &requests)
+	breaker := NewCircuitBreaker(3, time.Hour)
+	p := NewOllamaProvider("model", server.URL+"/api",
+		WithRetry(RetryPolicy{MaxRetries: 10, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}),
+		WithCircuitBreaker(breaker))
+
+	_, err := p.Embed(context.Background(), "text")
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.ErrorContains(t, err, "status 502")
+	assert.Equal(t, 3, requests)
+	assert.True(t, breaker.Open())
+
+	// the server is not contacted while the breaker is open
+	_, err = p.EmbedBatch(context.Background(), []string{"a", "b"})
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, 3, requests)
+}
+
+func TestCircuitBreakerCloses(t *testing.T) {
+	var requests int
+	server := newFlakyServer(t, 2, http.StatusBadGateway, nil, &requests)
+	breaker := NewCircuitBreaker(2, 20*time.Millisecond)
+	p := NewOllamaProvider("model", server.URL+"/api", WithRetry(fastRetry), WithCircuitBreaker(breaker))
+
+	_, err := p.Embed(context.Background(), "text")
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+
+	time.Sleep(30 * time.Millisecond)
+	assert.False(t, breaker.Open())
+	_, err = p.Embed(context.Background(), "text")
+	assert.NoError(t, err)
+}
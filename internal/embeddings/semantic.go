@@ -0,0 +1,192 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/jnaraujo/seekr/internal/textsplitter"
+	"github.com/jnaraujo/seekr/internal/vector"
+)
+
+const (
+	// DefaultBreakpointPercentile is the percentile of the similarities between neighbouring
+	// windows of sentences at or below which a chunk is ended.
+	DefaultBreakpointPercentile = 5
+	// DefaultSentenceWindow is the number of sentences on each side of a gap between two sentences
+	// that are compared, which keeps short sentences from being compared on their own.
+	DefaultSentenceWindow = 2
+)
+
+// SemanticSplitter chunks a text where its topic changes. Every sentence is embedded, and chunks
+// end between the windows of sentences whose embeddings are the least alike, those whose
+// similarity is at most a percentile of all of them. Chunks are kept at least
+// minSize long, unless the text ends first, and a chunk ends before it would grow past maxSize.
+// Sentences longer than maxSize are split like the RecursiveCharacterTextSplitter does.
+type SemanticSplitter struct {
+	provider   Provider
+	minSize    int
+	maxSize    int
+	percentile float64
+	window     int
+	// long splits the sentences longer than maxSize, and measures chunks.
+	long *textsplitter.RecursiveCharacterTextSplitter
+}
+
+func NewSemanticSplitter(provider Provider, minSize int, maxSize int, opts ...textsplitter.Option) *SemanticSplitter {
+	return &SemanticSplitter{
+		provider:   provider,
+		minSize:    minSize,
+		maxSize:    maxSize,
+		percentile: DefaultBreakpointPercentile,
+		window:     DefaultSentenceWindow,
+		long:       textsplitter.NewRecursiveCharacterTextSplitter(maxSize, 0, opts...),
+	}
+}
+
+// WithPercentile ends chunks where the similarity between neighbouring windows of sentences is at
+// most the given percentile, between 0 and 100, of all of them. The higher it is, the more chunks.
+func (s *SemanticSplitter) WithPercentile(percentile float64) *SemanticSplitter {
+	s.percentile = percentile
+	return s
+}
+
+// WithWindow compares the given number of sentences on each side of a gap between two sentences.
+func (s *SemanticSplitter) WithWindow(window int) *SemanticSplitter {
+	s.window = window
+	return s
+}
+
+// Split splits text into chunks of whole sentences that end where the topic changes, each along
+// with where it lies in text.
+func (s *SemanticSplitter) Split(ctx context.Context, text string) ([]textsplitter.Segment, error) {
+	sentences := textsplitter.Sentences(text)
+	if len(sentences) == 0 {
+		return nil, nil
+	}
+
+	similarities, err := s.similarities(ctx, sentences)
+	if err != nil {
+		return nil, err
+	}
+	threshold := percentile(similarities, s.percentile)
+	// a text as alike throughout as it gets has no breakpoints
+	breakpoints := len(similarities) > 0 && threshold < slices.Max(similarities)
+
+	var (
+		segments []textsplitter.Segment
+		// first is the first sentence of the chunk being filled, or -1
+		first = -1
+	)
+	flush := func(last int) {
+		if first >= 0 {
+			segments = append(segments, sentenceRange(text, sentences[first], sentences[last]))
+			first = -1
+		}
+	}
+
+	for i, sentence := range sentences {
+		if s.long.Length(sentence.Text) > s.maxSize {
+			flush(i - 1)
+			segments = append(segments, s.splitSentence(sentence)...)
+			continue
+		}
+		if first >= 0 && s.long.Length(text[sentences[first].Start:sentence.End]) > s.maxSize {
+			flush(i - 1)
+		}
+		if first < 0 {
+			first = i
+		}
+
+		// similarities[i] is that of the sentence with the next one
+		if breakpoints && i < len(similarities) && similarities[i] <= threshold &&
+			s.long.Length(text[sentences[first].Start:sentence.End]) >= s.minSize {
+			flush(i)
+		}
+	}
+	flush(len(sentences) - 1)
+	return segments, nil
+}
+
+// similarities embeds every sentence and returns, for every sentence but the last, the cosine
+// similarity between the window of sentences ending with it and the window starting after it,
+// each window being embedded as the sum of the embeddings of its sentences.
+func (s *SemanticSplitter) similarities(ctx context.Context, sentences []textsplitter.Segment) ([]float32, error) {
+	if len(sentences) < 2 {
+		return nil, nil
+	}
+
+	texts := make([]string, len(sentences))
+	for i, sentence := range sentences {
+		texts[i] = sentence.Text
+	}
+	vecs, err := s.provider.EmbedDocuments(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed sentences: %w", err)
+	}
+	if len(vecs) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(vecs))
+	}
+
+	window := max(s.window, 1)
+	similarities := make([]float32, len(vecs)-1)
+	for i := range similarities {
+		before := sum(vecs[max(i+1-window, 0) : i+1])
+		after := sum(vecs[i+1 : min(i+1+window, len(vecs))])
+		similarities[i] = vector.CosineSimilarity(before, after)
+	}
+	return similarities, nil
+}
+
+func sum(vecs [][]float32) []float32 {
+	total := make([]float32, len(vecs[0]))
+	for _, v := range vecs {
+		for i, x := range v {
+			if i < len(total) {
+				total[i] += x
+			}
+		}
+	}
+	return total
+}
+
+// splitSentence splits a sentence longer than a chunk with the recursive splitter.
+func (s *SemanticSplitter) splitSentence(sentence textsplitter.Segment) []textsplitter.Segment {
+	segments := s.long.Split(sentence.Text)
+	for i := range segments {
+		segments[i].Start += sentence.Start
+		segments[i].End += sentence.Start
+		segments[i].StartLine += sentence.StartLine - 1
+		segments[i].EndLine += sentence.StartLine - 1
+	}
+	return segments
+}
+
+// sentenceRange returns the segment of text from the first sentence to the last one.
+func sentenceRange(text string, first, last textsplitter.Segment) textsplitter.Segment {
+	return textsplitter.Segment{
+		Text:      text[first.Start:last.End],
+		Start:     first.Start,
+		End:       last.End,
+		StartLine: first.StartLine,
+		EndLine:   last.EndLine,
+	}
+}
+
+// percentile returns the p-th percentile of values, interpolating between the closest two.
+func percentile(values []float32, p float64) float32 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+
+	p = min(max(p, 0), 100)
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(rank)
+	if lower >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	frac := float32(rank - float64(lower))
+	return sorted[lower] + frac*(sorted[lower+1]-sorted[lower])
+}
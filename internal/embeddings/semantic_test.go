@@ -0,0 +1,108 @@
+package embeddings
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// topicProvider embeds a text as the number of times it mentions cats and cars.
+type topicProvider struct {
+	err error
+}
+
+func (p topicProvider) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	vecs, err := p.EmbedDocuments(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vecs[0], nil
+}
+
+func (p topicProvider) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	vecs := make([][]float32, len(texts))
+	for i, text := range texts {
+		vecs[i] = []float32{float32(strings.Count(text, "cat")), float32(strings.Count(text, "car"))}
+	}
+	return vecs, nil
+}
+
+func (p topicProvider) Dimension(ctx context.Context) (int, error) {
+	return 2, nil
+}
+
+func (p topicProvider) Health(ctx context.Context) (Health, error) {
+	return Health{Reachable: true, ModelAvailable: true, Dimension: 2}, nil
+}
+
+const topics = "The cat sleeps. A cat purrs. Every cat hunts. The car starts. A car honks. Every car stops."
+
+func TestSemanticSplitsAtTopicChanges(t *testing.T) {
+	segments, err := NewSemanticSplitter(topicProvider{}, 0, 1000).WithWindow(1).Split(context.Background(), topics)
+	assert.NoError(t, err)
+
+	assert.Len(t, segments, 2)
+	assert.Equal(t, "The cat sleeps. A cat purrs. Every cat hunts.", segments[0].Text)
+	assert.Equal(t, "The car starts. A car honks. Every car stops.", segments[1].Text)
+	for _, seg := range segments {
+		assert.Equal(t, seg.Text, topics[seg.Start:seg.End])
+	}
+
+	// wider windows still tell the topics apart
+	segments, err = NewSemanticSplitter(topicProvider{}, 0, 1000).Split(context.Background(), topics)
+	assert.NoError(t, err)
+	assert.Len(t, segments, 2)
+}
+
+func TestSemanticBoundsChunkSizes(t *testing.T) {
+	ctx := context.Background()
+
+	// the topic changes before the chunk is long enough
+	segments, err := NewSemanticSplitter(topicProvider{}, 60, 1000).WithWindow(1).Split(ctx, topics)
+	assert.NoError(t, err)
+	assert.Len(t, segments, 1)
+	assert.Equal(t, topics, segments[0].Text)
+
+	segments, err = NewSemanticSplitter(topicProvider{}, 0, 30).WithWindow(1).Split(ctx, topics)
+	assert.NoError(t, err)
+	assert.Equal(t, "The cat sleeps. A cat purrs.", segments[0].Text)
+	for _, seg := range segments {
+		assert.LessOrEqual(t, len(seg.Text), 30)
+	}
+
+	// a sentence longer than a chunk is split
+	long := "The cat " + strings.Repeat("sleeps and ", 10) + "wakes."
+	segments, err = NewSemanticSplitter(topicProvider{}, 0, 30).Split(ctx, long)
+	assert.NoError(t, err)
+	assert.Greater(t, len(segments), 1)
+	for _, seg := range segments {
+		assert.Equal(t, seg.Text, long[seg.Start:seg.End])
+	}
+}
+
+func TestSemanticReturnsEmbeddingErrors(t *testing.T) {
+	err := errors.New("server down")
+	_, got := NewSemanticSplitter(topicProvider{err: err}, 0, 1000).Split(context.Background(), topics)
+	assert.ErrorIs(t, got, err)
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float32{0.4, 0.1, 0.3, 0.2}
+	assert.Equal(t, float32(0.1), percentile(values, 0))
+	assert.InDelta(t, 0.25, percentile(values, 50), 1e-6)
+	assert.Equal(t, float32(0.4), percentile(values, 100))
+	assert.Equal(t, float32(0), percentile(nil, 50))
+}
+
+func TestSemanticWithoutBreakpoints(t *testing.T) {
+	text := "The cat sleeps. The cat sleeps. The cat sleeps."
+	segments, err := NewSemanticSplitter(topicProvider{}, 0, 1000).WithWindow(1).Split(context.Background(), text)
+	assert.NoError(t, err)
+	assert.Len(t, segments, 1)
+}
@@ -0,0 +1,46 @@
+// Package extract pulls the text out of the files documents are indexed from, along with what the
+// files tell about themselves.
+package extract
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ErrUnsupported is returned for files no extractor can read.
+var ErrUnsupported = errors.New("unsupported file type")
+
+// Metadata is what a file tells about itself. Fields a format does not have are left empty.
+type Metadata struct {
+	Title  string
+	Author string
+	// Pages is the number of pages of paginated formats.
+	Pages int
+}
+
+// Fields returns the fields that are set, keyed by their lowercase names.
+func (m Metadata) Fields() map[string]string {
+	fields := make(map[string]string)
+	if m.Title != "" {
+		fields["title"] = m.Title
+	}
+	if m.Author != "" {
+		fields["author"] = m.Author
+	}
+	if m.Pages > 0 {
+		fields["pages"] = strconv.Itoa(m.Pages)
+	}
+	return fields
+}
+
+// Result is the text extracted from a file along with its metadata.
+type Result struct {
+	Text     string
+	Metadata Metadata
+}
+
+type Extractor interface {
+	// Extract returns the text of a file of the format of the extractor, given its content. It
+	// returns an error wrapping ErrUnsupported if the content is not of that format.
+	Extract(content []byte) (Result, error)
+}
@@ -0,0 +1,115 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newPDF returns a PDF of a single page showing text, with the given title and author.
+func newPDF(text, title, author string) []byte {
+	stream := fmt.Sprintf("BT /F1 12 Tf 72 712 Td (%s) Tj ET", text)
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		fmt.Sprintf("<< /Title (%s) /Author (%s) >>", title, author),
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 6 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+func TestExtractPDF(t *testing.T) {
+	content := newPDF("Hello from the PDF", "Release Notes", "Jane Doe")
+
+	// by extension, and by magic bytes when the extension says nothing
+	for _, path := range []string{"notes.pdf", "notes.bin", "notes"} {
+		result, err := Extract(path, content)
+		assert.NoError(t, err, path)
+		assert.Contains(t, result.Text, "Hello from the PDF")
+		assert.Equal(t, Metadata{Title: "Release Notes", Author: "Jane Doe", Pages: 1}, result.Metadata)
+	}
+
+	_, err := Extract("broken.pdf", []byte("%PDF-1.4\nnot really"))
+	assert.Error(t, err)
+	_, err = Extract("fake.pdf", []byte("just text"))
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
+
+func TestExtractText(t *testing.T) {
+	result, err := Extract("notes.md", []byte("\xef\xbb\xbf# Notes\n\nSome text."))
+	assert.NoError(t, err)
+	assert.Equal(t, Result{Text: "# Notes\n\nSome text."}, result)
+
+	// sniffed as HTML, still text
+	result, err = Extract("page.html", []byte("<html><body>Hi</body></html>"))
+	assert.NoError(t, err)
+	assert.Equal(t, "<html><body>Hi</body></html>", result.Text)
+
+	// control characters make it sniff as binary, yet it is valid UTF-8
+	_, err = Extract("log.txt", []byte("line\x00one"))
+	assert.NoError(t, err)
+}
+
+func TestExtractRejectsBinaries(t *testing.T) {
+	_, err := Extract("image.png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\xff\xfe"))
+	assert.ErrorIs(t, err, ErrUnsupported)
+	assert.ErrorContains(t, err, "image/png")
+}
+
+// upperExtractor reads files as text in upper case, to tell it apart from TextExtractor.
+type upperExtractor struct{}
+
+func (upperExtractor) Extract(content []byte) (Result, error) {
+	return Result{Text: strings.ToUpper(string(content))}, nil
+}
+
+func TestRegister(t *testing.T) {
+	Register(".Shout", upperExtractor{})
+	t.Cleanup(func() { Unregister(".shout") })
+	result, err := Extract("notes.shout", []byte("hello"))
+	assert.NoError(t, err)
+	assert.Equal(t, "HELLO", result.Text)
+
+	RegisterMediaType("image/gif", upperExtractor{})
+	t.Cleanup(func() { UnregisterMediaType("image/gif") })
+	result, err = Extract("image", []byte("GIF89a"))
+	assert.NoError(t, err)
+	assert.Equal(t, "GIF89A", result.Text)
+}
+
+func TestUnregister(t *testing.T) {
+	Register(".shout", upperExtractor{})
+	Unregister(".SHOUT")
+	result, err := Extract("notes.shout", []byte("hello"))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", result.Text)
+
+	RegisterMediaType("image/gif", upperExtractor{})
+	UnregisterMediaType("image/gif")
+	_, err = Extract("image", []byte("GIF89a\xff\xfe"))
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
+
+func TestMetadataFields(t *testing.T) {
+	assert.Equal(t, map[string]string{"title": "Notes", "pages": "3"}, Metadata{Title: "Notes", Pages: 3}.Fields())
+	assert.Empty(t, Metadata{}.Fields())
+}
@@ -0,0 +1,48 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// PDFExtractor reads the text of the pages of a PDF, along with the title and author of its
+// document information and its page count.
+type PDFExtractor struct{}
+
+func (PDFExtractor) Extract(content []byte) (result Result, err error) {
+	if !bytes.HasPrefix(content, []byte("%PDF-")) {
+		return Result{}, fmt.Errorf("%w: content is not a PDF", ErrUnsupported)
+	}
+	// the reader panics on some malformed files instead of returning an error
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = Result{}, fmt.Errorf("failed to read pdf: %v", r)
+		}
+	}()
+
+	r, err := pdf.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read pdf: %w", err)
+	}
+	plainReader, err := r.GetPlainText()
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read pdf content: %w", err)
+	}
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(plainReader); err != nil {
+		return Result{}, fmt.Errorf("failed to read pdf content: %w", err)
+	}
+
+	info := r.Trailer().Key("Info")
+	return Result{
+		Text: buf.String(),
+		Metadata: Metadata{
+			Title:  strings.TrimSpace(info.Key("Title").Text()),
+			Author: strings.TrimSpace(info.Key("Author").Text()),
+			Pages:  r.NumPage(),
+		},
+	}, nil
+}
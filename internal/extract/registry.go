@@ -0,0 +1,95 @@
+package extract
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// mu guards byExtension and byMediaType, which Register and RegisterMediaType add to and
+// Unregister and UnregisterMediaType remove from.
+var mu sync.RWMutex
+
+// byExtension holds the extractors of the formats told apart by their file extension.
+var byExtension = map[string]Extractor{
+	".pdf": PDFExtractor{},
+}
+
+// byMediaType holds the extractors of the media types content is sniffed as, where "text/*"
+// stands for every text type.
+var byMediaType = map[string]Extractor{
+	"application/pdf": PDFExtractor{},
+	"text/*":          TextExtractor{},
+}
+
+// Register makes For pick e for the files with the extension ext, such as ".docx", in any case,
+// replacing the extractor registered for it before.
+func Register(ext string, e Extractor) {
+	mu.Lock()
+	defer mu.Unlock()
+	byExtension[strings.ToLower(ext)] = e
+}
+
+// RegisterMediaType makes For pick e for content sniffed as mediaType, such as "application/zip",
+// or as any subtype of a type given as "type/*", for files no extension is registered for.
+func RegisterMediaType(mediaType string, e Extractor) {
+	mu.Lock()
+	defer mu.Unlock()
+	byMediaType[mediaType] = e
+}
+
+// Unregister removes the extractor registered for the extension ext, so that For picks one by
+// the media type of the content again.
+func Unregister(ext string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(byExtension, strings.ToLower(ext))
+}
+
+// UnregisterMediaType removes the extractor registered for mediaType.
+func UnregisterMediaType(mediaType string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(byMediaType, mediaType)
+}
+
+// For returns the extractor for the file at path, picked by its extension or else by the media
+// type its content is sniffed as with http.DetectContentType, which knows the magic bytes of the
+// common formats. Content that is valid UTF-8 is read as text whatever it is sniffed as.
+func For(path string, content []byte) (Extractor, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if extractor, ok := byExtension[strings.ToLower(filepath.Ext(path))]; ok {
+		return extractor, nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(content))
+	if err == nil {
+		if extractor, ok := byMediaType[mediaType]; ok {
+			return extractor, nil
+		}
+		if kind, _, ok := strings.Cut(mediaType, "/"); ok {
+			if extractor, ok := byMediaType[kind+"/*"]; ok {
+				return extractor, nil
+			}
+		}
+	}
+	if utf8.Valid(content) {
+		return TextExtractor{}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupported, mediaType)
+}
+
+// Extract returns the text of the file at path, given its content, with the extractor for it.
+func Extract(path string, content []byte) (Result, error) {
+	extractor, err := For(path, content)
+	if err != nil {
+		return Result{}, err
+	}
+	return extractor.Extract(content)
+}
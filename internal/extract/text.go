@@ -0,0 +1,20 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+)
+
+// utf8BOM is the byte order mark some editors start UTF-8 files with.
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// TextExtractor reads plain text encoded in UTF-8, such as Markdown or source code.
+type TextExtractor struct{}
+
+func (TextExtractor) Extract(content []byte) (Result, error) {
+	if !utf8.Valid(content) {
+		return Result{}, fmt.Errorf("%w: content is not UTF-8 text", ErrUnsupported)
+	}
+	return Result{Text: string(bytes.TrimPrefix(content, utf8BOM))}, nil
+}
@@ -1,19 +1,22 @@
 package storage
 
 import (
+	"bufio"
 	"bytes"
 	"cmp"
 	"context"
-	"encoding/gob"
 	"errors"
 	"fmt"
 	"io"
-	"math"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"runtime"
 	"slices"
 	"sync"
+	"time"
 
+	"github.com/jnaraujo/seekr/internal/config"
 	"github.com/jnaraujo/seekr/internal/document"
 	"github.com/jnaraujo/seekr/internal/vector"
 )
@@ -22,28 +25,325 @@ type DiskStore struct {
 	filePath  string
 	mu        sync.RWMutex
 	file      *os.File
+	size      int64
+	header    fileHeader
 	documents []document.Document
+	// problems lists the corrupt records skipped while loading with WithSkipCorrupt.
+	problems    []Problem
+	skipCorrupt bool
+
+	// lock is held from NewDiskStore until Close when the store is opened WithLock.
+	// Stores opened with a shared lock are read-only.
+	lock        *Lock
+	lockMode    LockMode
+	lockTimeout time.Duration
+	locking     bool
+	readOnly    bool
+	// migrated holds the data of a read-only store of an older format version, which is only
+	// upgraded in memory, so records are read back from it rather than from the file.
+	migrated []byte
+
+	// index, when set, answers searches instead of scanning every chunk. indexKind is the one
+	// requested when opening the store, empty to keep the one in its header.
+	index        vectorIndex
+	indexKind    IndexKind
+	replaceIndex bool
+	hnswParams   HNSWParams
+	ivfParams    IVFParams
+
+	// model and dimension are the embeddings requested WithEmbedding, empty and 0 to take the
+	// ones of the store.
+	model     string
+	dimension int
+
+	// quantization is the one requested when opening the store, empty to keep the one in its header.
+	quantization  Quantization
+	headerChanged bool
+	// quantized holds the embeddings of every document when the store is quantized,
+	// the documents themselves are then kept without them.
+	quantized map[string]quantizedChunks
+	// pq holds the codebooks of a product quantized store once trained, pqPending counts the
+	// chunks held with their full embeddings until then.
+	pq        *vector.ProductQuantizer
+	pqParams  PQParams
+	pqPending int
+
+	// recordOffsets and recordSizes locate the add record backing each live document,
+	// liveSize is the sum of their sizes. Anything else in the log is reclaimable by compaction.
+	recordOffsets map[string]int64
+	recordSizes   map[string]int64
+	liveSize      int64
+
+	compactMinBytes int64
+	compactRatio    float64
+	compacting      bool
+	background      sync.WaitGroup
+
+	// searchWorkers is the number of goroutines a search scanning every document is spread over.
+	searchWorkers int
 }
 
 // checks if DiskStore implements the Store interface
 var _ Store = &DiskStore{}
+var _ Compactor = &DiskStore{}
+var _ IndexRebuilder = &DiskStore{}
 
-func NewDiskStore(path string) (*DiskStore, error) {
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+const (
+	defaultCompactMinBytes = 1 << 20
+	defaultCompactRatio    = 0.5
+)
+
+func NewDiskStore(path string, opts ...Option) (*DiskStore, error) {
+	ds := &DiskStore{
+		filePath:        path,
+		header:          fileHeader{Model: config.DefaultEmbeddingModel, Quantization: NoQuantization, Index: BruteForceIndex},
+		documents:       make([]document.Document, 0),
+		quantized:       make(map[string]quantizedChunks),
+		hnswParams:      DefaultHNSWParams(),
+		ivfParams:       DefaultIVFParams(),
+		pqParams:        DefaultPQParams(),
+		recordOffsets:   make(map[string]int64),
+		recordSizes:     make(map[string]int64),
+		compactMinBytes: defaultCompactMinBytes,
+		compactRatio:    defaultCompactRatio,
+		searchWorkers:   runtime.NumCPU(),
+	}
+	for _, opt := range opts {
+		opt(ds)
+	}
+	if ds.quantization != "" {
+		ds.header.Quantization = ds.quantization
+	}
+
+	if ds.locking {
+		lock, err := AcquireLock(path, ds.lockMode, ds.lockTimeout)
+		if err != nil {
+			return nil, err
+		}
+		ds.lock = lock
+	}
+
+	if err := ds.load(); err != nil {
+		ds.lock.Release()
+		return nil, err
+	}
+
+	if err := ds.checkEmbedding(); err != nil {
+		ds.lock.Release()
+		return nil, err
+	}
+
+	if err := ds.checkIndex(); err != nil {
+		ds.lock.Release()
+		return nil, err
+	}
+
+	if ds.header.Quantization != NoQuantization && ds.header.Index != BruteForceIndex {
+		ds.lock.Release()
+		return nil, fmt.Errorf("the %s index cannot be used with a store quantized to %s", ds.header.Index, ds.header.Quantization)
+	}
+
+	var err error
+	if ds.readOnly {
+		err = ds.openReader()
+	} else {
+		err = ds.open()
+	}
 	if err != nil {
+		ds.lock.Release()
 		return nil, err
 	}
 
-	ds := &DiskStore{filePath: path, file: f, documents: make([]document.Document, 0)}
+	ds.openIndex()
+
+	return ds, nil
+}
+
+// checkEmbedding makes sure the store holds embeddings of the model and dimension requested
+// WithEmbedding. A store without documents is switched over to them instead, which read-only
+// stores only do in memory.
+func (ds *DiskStore) checkEmbedding() error {
+	modelDiffers := ds.model != "" && ds.model != ds.header.Model
+	dimensionDiffers := ds.dimension > 0 && ds.header.Dimension > 0 && ds.dimension != ds.header.Dimension
+	if !modelDiffers && !dimensionDiffers {
+		if ds.header.Dimension == 0 && ds.dimension > 0 {
+			ds.header.Dimension = ds.dimension
+			ds.headerChanged = true
+		}
+		return nil
+	}
+
+	if len(ds.documents) > 0 {
+		return fmt.Errorf("%w: the store holds embeddings of %s, not of %s",
+			ErrEmbeddingMismatch, describeEmbedding(ds.header.Model, ds.header.Dimension), describeEmbedding(ds.model, ds.dimension))
+	}
+	if ds.model != "" {
+		ds.header.Model = ds.model
+	}
+	ds.header.Dimension = ds.dimension
+	ds.headerChanged = true
+	return nil
+}
+
+// checkIndex makes sure the store uses the vector index requested WithIndex. A store without an
+// index is switched over to it, which read-only stores only do in memory, and one recorded with
+// another index is refused unless WithIndexReplacement was passed.
+func (ds *DiskStore) checkIndex() error {
+	if ds.indexKind == "" || ds.indexKind == ds.header.Index {
+		return nil
+	}
+	if ds.header.Index != BruteForceIndex && !ds.replaceIndex {
+		return fmt.Errorf("%w: the store uses the %s index, not %s", ErrIndexMismatch, ds.header.Index, ds.indexKind)
+	}
+	ds.header.Index = ds.indexKind
+	ds.headerChanged = true
+	return nil
+}
+
+func describeEmbedding(model string, dimension int) string {
+	if dimension == 0 {
+		return fmt.Sprintf("%q", model)
+	}
+	return fmt.Sprintf("%q (%d dimensions)", model, dimension)
+}
+
+// checkDimension makes sure every chunk of doc has the dimension of the store. The first document
+// indexed into a store that does not know its dimension yet sets it.
+func (ds *DiskStore) checkDimension(doc document.Document) error {
+	if len(doc.Chunks) == 0 {
+		return nil
+	}
+	if ds.header.Dimension == 0 {
+		header := ds.header
+		header.Dimension = len(doc.Chunks[0].Embedding)
+		if err := updateFileHeader(ds.file, header); err != nil {
+			return err
+		}
+		ds.header = header
+	}
+	for i, chunk := range doc.Chunks {
+		if len(chunk.Embedding) != ds.header.Dimension {
+			return fmt.Errorf("%w: chunk %d has %d dimensions, the store holds %d",
+				ErrEmbeddingMismatch, i, len(chunk.Embedding), ds.header.Dimension)
+		}
+	}
+	return nil
+}
+
+// openIndex loads the vector index saved alongside the store and catches it up with
+// documents indexed or removed since it was saved.
+func (ds *DiskStore) openIndex() {
+	switch ds.header.Index {
+	case HNSWIndex:
+		ds.index = loadHNSWGraph(hnswPath(ds.filePath), ds.hnswParams, ds.documents)
+	case IVFIndex:
+		ds.index = loadIVFIndex(ivfPath(ds.filePath), ds.ivfParams, ds.documents)
+	default:
+		return
+	}
+	ds.index.sync(ds.documents)
+}
+
+func (ds *DiskStore) indexPath() string {
+	switch ds.header.Index {
+	case HNSWIndex:
+		return hnswPath(ds.filePath)
+	case IVFIndex:
+		return ivfPath(ds.filePath)
+	}
+	return ""
+}
 
-	err = ds.load()
+// RebuildIndex builds the vector index again from every document in the store and saves it.
+// Product quantized stores retrain their codebooks instead.
+func (ds *DiskStore) RebuildIndex(ctx context.Context) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if ds.readOnly {
+		return ErrReadOnly
+	}
+	if ds.header.Quantization == ProductQuantization {
+		return ds.trainCodebooks()
+	}
+	if ds.index == nil {
+		return ErrNoIndex
+	}
+
+	ds.index.build(ds.documents)
+	return ds.index.save(ds.indexPath())
+}
+
+// open opens the store file for appending, writing the file header if the store is new
+// and dropping any partially written record left at the end of the log.
+func (ds *DiskStore) open() error {
+	f, err := os.OpenFile(ds.filePath, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
 	if err != nil {
 		f.Close()
-		return nil, err
+		return err
+	}
+
+	if info.Size() == 0 {
+		if err := writeFileHeader(f, ds.header); err != nil {
+			f.Close()
+			return fmt.Errorf("open: failed to write file header: %w", err)
+		}
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return fmt.Errorf("open: failed to sync file header: %w", err)
+		}
+		ds.size = int64(fileHeaderSize)
+	} else if info.Size() > ds.size {
+		if err := f.Truncate(ds.size); err != nil {
+			f.Close()
+			return fmt.Errorf("open: failed to truncate incomplete record: %w", err)
+		}
+	}
+
+	if ds.headerChanged && info.Size() > 0 {
+		if err := updateFileHeader(f, ds.header); err != nil {
+			f.Close()
+			return fmt.Errorf("open: %w", err)
+		}
+		ds.headerChanged = false
+	}
+
+	ds.file = f
+	return nil
+}
+
+// updateFileHeader overwrites the header at the start of the store file f in place.
+func updateFileHeader(f *os.File, h fileHeader) error {
+	var header bytes.Buffer
+	if err := writeFileHeader(&header, h); err != nil {
+		return fmt.Errorf("failed to encode file header: %w", err)
+	}
+	if _, err := f.WriteAt(header.Bytes(), 0); err != nil {
+		return fmt.Errorf("failed to update file header: %w", err)
 	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync file header: %w", err)
+	}
+	return nil
+}
 
-	_, err = f.Seek(0, io.SeekEnd)
-	return ds, err
+// openReader opens the store file of a read-only store, through which searches on binary and pq
+// quantized stores read back full embeddings. A store that does not exist yet is left closed.
+func (ds *DiskStore) openReader() error {
+	f, err := os.Open(ds.filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	ds.file = f
+	return nil
 }
 
 func (ds *DiskStore) load() error {
@@ -58,125 +358,437 @@ func (ds *DiskStore) load() error {
 		return err
 	}
 
+	version := detectVersion(data)
+	data, err = upgrade(data)
+	if err != nil {
+		return err
+	}
+	if version < currentVersion {
+		// read-only stores are upgraded in memory and left for the next writer to rewrite
+		if ds.readOnly {
+			ds.migrated = data
+		} else {
+			err := writeFileAtomic(ds.filePath, func(w *bufio.Writer) error {
+				_, err := w.Write(data)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to write migrated store: %w", err)
+			}
+		}
+	}
+
+	return ds.replay(data)
+}
+
+// upgrade migrates the store file data to the current format version, refusing files written by
+// newer versions.
+func upgrade(data []byte) ([]byte, error) {
+	version := detectVersion(data)
+	if version > currentVersion {
+		return nil, fmt.Errorf("%w %d: the store was written by a newer version of %s (this build supports up to %d)",
+			ErrUnsupportedVersion, version, config.AppName, currentVersion)
+	}
+	if version < currentVersion {
+		return migrate(data, version)
+	}
+	return data, nil
+}
+
+// replay rebuilds the in-memory documents from the record log.
+func (ds *DiskStore) replay(data []byte) error {
 	if len(data) == 0 {
 		return nil
 	}
 
-	decoder := gob.NewDecoder(bytes.NewReader(data))
-	if err := decoder.Decode(&ds.documents); err != nil {
-		if err == io.EOF {
-			return nil
+	header, err := readFileHeader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if ds.quantization != "" && ds.quantization != header.Quantization {
+		// the records keep full embeddings, so switching only needs the header updated
+		header.Quantization = ds.quantization
+		ds.headerChanged = true
+	}
+	ds.header = header
+	if header.Quantization == ProductQuantization {
+		ds.loadCodebooks()
+	}
+
+	end, problems := walkRecords(data[fileHeaderSize:], int64(fileHeaderSize), ds.apply)
+	if end < int64(len(data)) {
+		slog.Warn("store ends with an incomplete record, discarding it", "offset", end)
+	}
+
+	if len(problems) > 0 {
+		if !ds.skipCorrupt {
+			first := problems[0]
+			return fmt.Errorf("%w: %d bad record(s), first at offset %d: %s",
+				ErrCorrupt, len(problems), first.Offset, first.Reason)
 		}
-		return fmt.Errorf("failed to decode gob data: %w", err)
+		for _, p := range problems {
+			slog.Warn("skipping corrupt record", "offset", p.Offset, "size", p.Size, "reason", p.Reason)
+		}
+		ds.problems = problems
 	}
 
+	ds.size = end
+	ds.maybeTrainCodebooks()
 	return nil
 }
 
-func (ds *DiskStore) persist() error {
-	tempFile, err := os.CreateTemp(filepath.Dir(ds.filePath), filepath.Base(ds.filePath)+".*.tmp")
+func (ds *DiskStore) apply(offset int64, rec record, size int64) error {
+	switch rec.kind {
+	case recordAdd:
+		doc, err := decodeDocument(rec.payload)
+		if err != nil {
+			return fmt.Errorf("failed to decode document: %w", err)
+		}
+		i := ds.indexOf(doc.ID)
+		if i != -1 {
+			ds.release(ds.documents[i])
+		}
+		held, err := ds.hold(doc)
+		if err != nil {
+			// the version the record replaces is gone along with it
+			if i != -1 {
+				ds.documents = slices.Delete(ds.documents, i, i+1)
+				ds.setRecord(doc.ID, 0, 0)
+			}
+			return err
+		}
+		if i != -1 {
+			ds.documents[i] = held
+		} else {
+			ds.documents = append(ds.documents, held)
+		}
+		ds.setRecord(doc.ID, offset, size)
+	case recordRemove:
+		id := string(rec.payload)
+		if i := ds.indexOf(id); i != -1 {
+			ds.release(ds.documents[i])
+			ds.documents = slices.Delete(ds.documents, i, i+1)
+		}
+		ds.setRecord(id, 0, 0)
+	}
+	return nil
+}
+
+// setRecord updates where the add record of a document is and the live size accounting,
+// a size of 0 meaning the document was removed.
+func (ds *DiskStore) setRecord(id string, offset, size int64) {
+	ds.liveSize -= ds.recordSizes[id]
+	if size == 0 {
+		delete(ds.recordOffsets, id)
+		delete(ds.recordSizes, id)
+		return
+	}
+	ds.recordOffsets[id] = offset
+	ds.recordSizes[id] = size
+	ds.liveSize += size
+}
+
+// readDocument reads the add record of a live document back from the store file,
+// with its full embeddings.
+func (ds *DiskStore) readDocument(id string) (document.Document, error) {
+	size, ok := ds.recordSizes[id]
+	if !ok {
+		return document.Document{}, ErrNotFound
+	}
+	if ds.file == nil {
+		return document.Document{}, errors.New("store is closed")
+	}
+
+	buf := make([]byte, size)
+	if _, err := ds.records().ReadAt(buf, ds.recordOffsets[id]); err != nil {
+		return document.Document{}, err
+	}
+	rec, _, err := parseRecord(buf)
 	if err != nil {
-		return fmt.Errorf("persist: failed to create temporary file: %w", err)
+		return document.Document{}, err
+	}
+	return decodeDocument(rec.payload)
+}
+
+// records returns what the records of the store are read back from, the store file or the data
+// it was migrated to in memory.
+func (ds *DiskStore) records() io.ReaderAt {
+	if ds.migrated != nil {
+		return bytes.NewReader(ds.migrated)
+	}
+	return ds.file
+}
+
+// writeFileAtomic replaces the file at path with the output of write, going through
+// a temporary file in the same directory and a rename.
+func writeFileAtomic(path string, write func(w *bufio.Writer) error) error {
+	tempFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
 	}
 	tempFilePath := tempFile.Name()
 
-	encoder := gob.NewEncoder(tempFile)
-	if err := encoder.Encode(ds.documents); err != nil {
+	w := bufio.NewWriter(tempFile)
+	if err := write(w); err != nil {
 		tempFile.Close()
 		os.Remove(tempFilePath)
-		return fmt.Errorf("persist: failed to encode documents to gob: %w", err)
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		tempFile.Close()
+		os.Remove(tempFilePath)
+		return fmt.Errorf("failed to write temporary file: %w", err)
 	}
 
 	if err := tempFile.Sync(); err != nil {
 		tempFile.Close()
 		os.Remove(tempFilePath)
-		return fmt.Errorf("persist: failed to sync temporary file: %w", err)
+		return fmt.Errorf("failed to sync temporary file: %w", err)
 	}
 
 	if err := tempFile.Close(); err != nil {
 		os.Remove(tempFilePath)
-		return fmt.Errorf("persist: failed to close temporary file: %w", err)
+		return fmt.Errorf("failed to close temporary file: %w", err)
 	}
 
-	if err := os.Rename(tempFilePath, ds.filePath); err != nil {
-		return fmt.Errorf("persist: failed to rename temporary file: %w", err)
+	if err := os.Rename(tempFilePath, path); err != nil {
+		os.Remove(tempFilePath)
+		return fmt.Errorf("failed to rename temporary file: %w", err)
 	}
 
 	return nil
 }
 
+// persist rewrites the whole store with only the live documents, replacing the
+// current file through a temporary file and a rename. The add records of the live
+// documents are copied as they are, since quantized stores hold no full embeddings to encode.
+func (ds *DiskStore) persist() error {
+	if ds.file == nil {
+		return errors.New("store is closed")
+	}
+
+	recordOffsets := make(map[string]int64, len(ds.documents))
+	recordSizes := make(map[string]int64, len(ds.documents))
+	size := int64(fileHeaderSize)
+	err := writeFileAtomic(ds.filePath, func(w *bufio.Writer) error {
+		if err := writeFileHeader(w, ds.header); err != nil {
+			return fmt.Errorf("failed to write file header: %w", err)
+		}
+		for _, doc := range ds.documents {
+			rec := make([]byte, ds.recordSizes[doc.ID])
+			if _, err := ds.file.ReadAt(rec, ds.recordOffsets[doc.ID]); err != nil {
+				return fmt.Errorf("failed to read document %q: %w", doc.ID, err)
+			}
+			if _, err := w.Write(rec); err != nil {
+				return err
+			}
+			recordOffsets[doc.ID] = size
+			recordSizes[doc.ID] = int64(len(rec))
+			size += int64(len(rec))
+		}
+
+		// the open handle would keep pointing at the replaced file
+		ds.file.Close()
+		ds.file = nil
+		return nil
+	})
+	if err != nil {
+		if ds.file == nil {
+			if openErr := ds.open(); openErr != nil {
+				return errors.Join(fmt.Errorf("persist: %w", err), openErr)
+			}
+		}
+		return fmt.Errorf("persist: %w", err)
+	}
+
+	ds.size = size
+	ds.recordOffsets = recordOffsets
+	ds.recordSizes = recordSizes
+	ds.liveSize = size - int64(fileHeaderSize)
+
+	return ds.open()
+}
+
+// appendRecord writes a record at the end of the log and syncs it to disk.
+// On failure the log is truncated back to its previous size.
+func (ds *DiskStore) appendRecord(rec []byte) error {
+	if ds.file == nil {
+		return errors.New("store is closed")
+	}
+
+	if _, err := ds.file.WriteAt(rec, ds.size); err != nil {
+		ds.file.Truncate(ds.size)
+		return fmt.Errorf("append: failed to write record: %w", err)
+	}
+
+	if err := ds.file.Sync(); err != nil {
+		ds.file.Truncate(ds.size)
+		return fmt.Errorf("append: failed to sync record: %w", err)
+	}
+
+	ds.size += int64(len(rec))
+	return nil
+}
+
 func (ds *DiskStore) Index(ctx context.Context, document document.Document) error {
 	ds.mu.Lock()
 	defer ds.mu.Unlock()
 
+	if ds.readOnly {
+		return ErrReadOnly
+	}
+
 	_, err := ds.getInternal(ctx, document.ID)
 	if !errors.Is(err, ErrNotFound) {
 		return err
 	}
 
-	ds.documents = append(ds.documents, document)
+	if err := ds.checkDimension(document); err != nil {
+		return err
+	}
 
-	return ds.persist()
+	rec, err := encodeAddRecord(document)
+	if err != nil {
+		return fmt.Errorf("failed to encode document: %w", err)
+	}
+	held, err := ds.hold(document)
+	if err != nil {
+		return err
+	}
+	offset := ds.size
+	if err := ds.appendRecord(rec); err != nil {
+		ds.release(held)
+		return err
+	}
+
+	ds.documents = append(ds.documents, held)
+	ds.setRecord(document.ID, offset, int64(len(rec)))
+	if ds.index != nil {
+		ds.index.add(document)
+	}
+	ds.maybeTrainCodebooks()
+	return nil
 }
 
 func (ds *DiskStore) Remove(ctx context.Context, id string) error {
 	ds.mu.Lock()
 	defer ds.mu.Unlock()
 
-	foundIndex := -1
-	for i, e := range ds.documents {
-		if e.ID == id {
-			foundIndex = i
-			break
-		}
+	if ds.readOnly {
+		return ErrReadOnly
 	}
 
+	foundIndex := ds.indexOf(id)
 	if foundIndex == -1 {
 		return ErrNotFound
 	}
 
+	if err := ds.appendRecord(encodeRemoveRecord(id)); err != nil {
+		return err
+	}
+
+	ds.release(ds.documents[foundIndex])
 	ds.documents = slices.Delete(ds.documents, foundIndex, foundIndex+1)
-	return ds.persist()
+	ds.setRecord(id, 0, 0)
+	if ds.index != nil {
+		ds.index.remove(id)
+	}
+	ds.maybeCompact()
+	return nil
+}
+
+// Problems returns the corrupt records that were skipped when the store was loaded.
+func (ds *DiskStore) Problems() []Problem {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	return slices.Clone(ds.problems)
+}
+
+// Embedding returns the model and dimension of the embeddings held by the store.
+func (ds *DiskStore) Embedding() (model string, dimension int) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	return ds.header.Model, ds.header.Dimension
+}
+
+// VectorIndex returns the vector index answering searches, BruteForceIndex when there is none.
+func (ds *DiskStore) VectorIndex() IndexKind {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	return ds.header.Index
+}
+
+// Quantization returns how the store holds embeddings in memory.
+func (ds *DiskStore) Quantization() Quantization {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	return ds.header.Quantization
+}
+
+func (ds *DiskStore) indexOf(id string) int {
+	for i, e := range ds.documents {
+		if e.ID == id {
+			return i
+		}
+	}
+	return -1
 }
 
 func (ds *DiskStore) Search(ctx context.Context, query []float32, topK int) ([]SearchResult, error) {
 	ds.mu.RLock()
 	defer ds.mu.RUnlock()
 
+	if ds.header.Dimension > 0 && len(query) != ds.header.Dimension {
+		return nil, fmt.Errorf("%w: the query has %d dimensions, the store holds %d",
+			ErrEmbeddingMismatch, len(query), ds.header.Dimension)
+	}
+
 	query = vector.Normalize(query)
 
-	if len(ds.documents) == 0 {
+	if len(ds.documents) == 0 || topK <= 0 {
 		return []SearchResult{}, nil
 	}
 
-	results := make([]SearchResult, 0, len(ds.documents))
-
-	for _, doc := range ds.documents {
-		var bestScore float32
-		var bestChunkIndex = 0
-		for i, chunk := range doc.Chunks {
-			score := vector.FastCosineSimilarity(query, chunk.Embedding)
-			if score > bestScore {
-				bestScore = score
-				bestChunkIndex = i
-			}
+	if ds.index != nil && ds.index.ready() {
+		return ds.searchIndex(ctx, query, topK)
+	}
+	score := ds.scorer(query)
+	if limit := ds.rescoreLimit(topK); limit > 0 {
+		candidates, err := ds.topDocuments(ctx, limit, score)
+		if err != nil {
+			return nil, err
 		}
-		if bestScore <= 0 {
-			continue
+		return ds.rescore(candidates, query, topK)
+	}
+
+	candidates, err := ds.topDocuments(ctx, topK, score)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(candidates))
+	for _, c := range candidates {
+		if c.score <= 0 {
+			break
 		}
+		doc := ds.documents[c.id]
+		// only the winners need to know which of their chunks matched best
+		_, bestChunkIndex := score(doc)
 		results = append(results, SearchResult{
 			Document:          doc,
-			Score:             bestScore,
+			Score:             c.score,
 			BestMatchingChunk: bestChunkIndex,
 		})
 	}
+	return results, nil
+}
 
+// sortResults orders results by descending score.
+func sortResults(results []SearchResult) {
 	slices.SortFunc(results, func(a, b SearchResult) int {
 		return cmp.Compare(b.Score, a.Score)
 	})
-
-	max := int(math.Min(float64(topK), float64(len(ds.documents))))
-	return results[:max], nil
 }
 
 func (ds *DiskStore) Get(ctx context.Context, id string) (document.Document, error) {
@@ -205,5 +817,86 @@ func (ds *DiskStore) List(ctx context.Context) ([]document.Document, error) {
 }
 
 func (ds *DiskStore) Close() error {
-	return nil
+	ds.background.Wait()
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	var err error
+	if ds.index != nil && ds.index.dirty() && !ds.readOnly {
+		err = ds.index.save(ds.indexPath())
+	}
+	if ds.file != nil {
+		if closeErr := ds.file.Close(); err == nil {
+			err = closeErr
+		}
+		ds.file = nil
+	}
+	if lockErr := ds.lock.Release(); err == nil {
+		err = lockErr
+	}
+	ds.lock = nil
+	return err
+}
+
+// Compact rewrites the store keeping only the live documents.
+func (ds *DiskStore) Compact(ctx context.Context) (CompactStats, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	return ds.compact()
+}
+
+func (ds *DiskStore) compact() (CompactStats, error) {
+	if ds.readOnly {
+		return CompactStats{}, ErrReadOnly
+	}
+	if ds.file == nil {
+		return CompactStats{}, errors.New("store is closed")
+	}
+
+	before := ds.size
+	if err := ds.persist(); err != nil {
+		return CompactStats{}, fmt.Errorf("compact: %w", err)
+	}
+
+	return CompactStats{BytesBefore: before, BytesAfter: ds.size}, nil
+}
+
+// reclaimable returns how many bytes of the log are taken by removed or superseded records.
+func (ds *DiskStore) reclaimable() int64 {
+	return ds.size - int64(fileHeaderSize) - ds.liveSize
+}
+
+// maybeCompact starts a background compaction once enough of the log is reclaimable.
+// It must be called with ds.mu held.
+func (ds *DiskStore) maybeCompact() {
+	if ds.compacting || ds.compactMinBytes <= 0 {
+		return
+	}
+
+	dead := ds.reclaimable()
+	if dead < ds.compactMinBytes || float64(dead) < ds.compactRatio*float64(ds.size) {
+		return
+	}
+
+	ds.compacting = true
+	ds.background.Add(1)
+	go func() {
+		defer ds.background.Done()
+
+		ds.mu.Lock()
+		defer ds.mu.Unlock()
+		ds.compacting = false
+
+		if ds.file == nil {
+			return
+		}
+		stats, err := ds.compact()
+		if err != nil {
+			slog.Warn("background compaction failed", "error", err)
+			return
+		}
+		slog.Debug("store compacted", "reclaimed", stats.Reclaimed())
+	}()
 }
@@ -2,12 +2,16 @@ package storage
 
 import (
 	"context"
+	"encoding/gob"
 	"fmt"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 	"time"
 
+	"github.com/jnaraujo/seekr/internal/config"
 	"github.com/jnaraujo/seekr/internal/document"
 	"github.com/jnaraujo/seekr/internal/embeddings"
 	"github.com/jnaraujo/seekr/internal/vector"
@@ -215,3 +219,329 @@ func createDocumentsOnDisk(t *testing.T, initialDocs []document.Document) []docu
 
 	return docs
 }
+
+func TestIndexAppendsToLog(t *testing.T) {
+	ds, cleanup := makeTempStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	info, err := os.Stat(ds.filePath)
+	assert.NoError(t, err)
+	sizeBefore := info.Size()
+
+	doc, _ := document.NewDocument("a", []embeddings.Chunk{{Embedding: []float32{1, 0}}}, time.Now(), "path/example")
+	assert.NoError(t, ds.Index(ctx, doc))
+
+	rec, err := encodeAddRecord(doc)
+	assert.NoError(t, err)
+	info, err = os.Stat(ds.filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, sizeBefore+int64(len(rec)), info.Size())
+
+	assert.NoError(t, ds.Remove(ctx, "a"))
+	info, err = os.Stat(ds.filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, sizeBefore+int64(len(rec))+int64(len(encodeRemoveRecord("a"))), info.Size())
+}
+
+func TestLoadDiscardsIncompleteRecord(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "store.skdb")
+
+	ds, err := NewDiskStore(file)
+	assert.NoError(t, err)
+	doc, _ := document.NewDocument("a", []embeddings.Chunk{{Embedding: []float32{1, 0}}}, time.Now(), "path/example")
+	assert.NoError(t, ds.Index(context.Background(), doc))
+	validSize := ds.size
+	assert.NoError(t, ds.Close())
+
+	// simulate a crash in the middle of an append
+	rec, _ := encodeAddRecord(document.Document{ID: "b"})
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0o644)
+	assert.NoError(t, err)
+	_, err = f.Write(rec[:len(rec)/2])
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	ds2, err := NewDiskStore(file)
+	assert.NoError(t, err)
+	defer ds2.Close()
+
+	docs, err := ds2.List(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, docs, 1)
+
+	info, err := os.Stat(file)
+	assert.NoError(t, err)
+	assert.Equal(t, validSize, info.Size())
+}
+
+func TestLoadLegacyStore(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "store.skdb")
+
+	legacyDocs := []document.Document{{ID: "a", Path: "path/a"}, {ID: "b", Path: "path/b"}}
+	f, err := os.Create(file)
+	assert.NoError(t, err)
+	assert.NoError(t, gob.NewEncoder(f).Encode(legacyDocs))
+	assert.NoError(t, f.Close())
+
+	ds, err := NewDiskStore(file)
+	assert.NoError(t, err)
+
+	docs, err := ds.List(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, docs, 2)
+	assert.NoError(t, ds.Index(context.Background(), document.Document{ID: "c"}))
+	assert.NoError(t, ds.Close())
+
+	ds2, err := NewDiskStore(file)
+	assert.NoError(t, err)
+	defer ds2.Close()
+
+	docs, err = ds2.List(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, docs, 3)
+}
+
+func TestCompact(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "store.skdb")
+	ctx := context.Background()
+
+	ds, err := NewDiskStore(file, WithCompactionThreshold(0, 0))
+	assert.NoError(t, err)
+
+	for _, id := range []string{"a", "b", "c"} {
+		doc, _ := document.NewDocument(id, []embeddings.Chunk{{Embedding: []float32{1, 0}}}, time.Now(), "path/"+id)
+		assert.NoError(t, ds.Index(ctx, doc))
+	}
+	assert.NoError(t, ds.Remove(ctx, "a"))
+	assert.NoError(t, ds.Remove(ctx, "c"))
+
+	stats, err := ds.Compact(ctx)
+	assert.NoError(t, err)
+	assert.Greater(t, stats.Reclaimed(), int64(0))
+	assert.Equal(t, int64(0), ds.reclaimable())
+
+	info, err := os.Stat(file)
+	assert.NoError(t, err)
+	assert.Equal(t, stats.BytesAfter, info.Size())
+
+	// the store must still be writable after the file was replaced
+	doc, _ := document.NewDocument("d", []embeddings.Chunk{{Embedding: []float32{0, 1}}}, time.Now(), "path/d")
+	assert.NoError(t, ds.Index(ctx, doc))
+	assert.NoError(t, ds.Close())
+
+	ds2, err := NewDiskStore(file)
+	assert.NoError(t, err)
+	defer ds2.Close()
+
+	docs, err := ds2.List(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, docs, 2)
+	assert.Equal(t, "b", docs[0].ID)
+	assert.Equal(t, "d", docs[1].ID)
+}
+
+func TestAutomaticCompaction(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "store.skdb")
+	ctx := context.Background()
+
+	ds, err := NewDiskStore(file, WithCompactionThreshold(1, 0.5))
+	assert.NoError(t, err)
+
+	for _, id := range []string{"a", "b"} {
+		doc, _ := document.NewDocument(id, []embeddings.Chunk{{Embedding: []float32{1, 0}}}, time.Now(), "path/"+id)
+		assert.NoError(t, ds.Index(ctx, doc))
+	}
+	assert.NoError(t, ds.Remove(ctx, "a"))
+	assert.NoError(t, ds.Remove(ctx, "b"))
+	assert.NoError(t, ds.Close())
+
+	info, err := os.Stat(file)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(fileHeaderSize), info.Size())
+}
+
+func TestMigrateFromRecordLogWithoutEmbeddingHeader(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "store.skdb")
+
+	rec, err := encodeAddRecord(document.Document{ID: "a", Path: "path/a"})
+	assert.NoError(t, err)
+	data := append([]byte{'S', 'K', 'D', 'B', 1, 0}, rec...)
+	data = append(data, encodeRemoveRecord("a")...)
+	rec, err = encodeAddRecord(document.Document{ID: "b", Path: "path/b"})
+	assert.NoError(t, err)
+	data = append(data, rec...)
+	assert.NoError(t, os.WriteFile(file, data, 0o644))
+
+	ds, err := NewDiskStore(file)
+	assert.NoError(t, err)
+	defer ds.Close()
+
+	docs, err := ds.List(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, docs, 1)
+	assert.Equal(t, "b", docs[0].ID)
+
+	model, dimension := ds.Embedding()
+	assert.Equal(t, config.DefaultEmbeddingModel, model)
+	assert.Equal(t, config.EmbeddingDimension, dimension)
+
+	data, err = os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, currentVersion, detectVersion(data))
+}
+
+func TestRefuseNewerVersion(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "store.skdb")
+	assert.NoError(t, os.WriteFile(file, []byte{'S', 'K', 'D', 'B', 0xff, 0}, 0o644))
+
+	_, err := NewDiskStore(file)
+	assert.ErrorIs(t, err, ErrUnsupportedVersion)
+}
+
+// makeSearchStore returns a store holding n documents of three random chunks each,
+// set up in memory since searches never touch the file of an unquantized store.
+func makeSearchStore(t testing.TB, n, dim int) *DiskStore {
+	ds, err := NewDiskStore(filepath.Join(t.TempDir(), "store.skdb"))
+	assert.NoError(t, err)
+
+	rng := rand.New(rand.NewSource(1))
+	for i := range n {
+		chunks := make([]embeddings.Chunk, 3)
+		for j := range chunks {
+			chunks[j].Embedding = randomUnitVector(rng, dim)
+		}
+		doc, _ := document.NewDocument(fmt.Sprintf("doc-%d", i), chunks, time.Now(), fmt.Sprintf("path/%d", i))
+		ds.documents = append(ds.documents, doc)
+	}
+	return ds
+}
+
+func TestParallelSearchMatchesSequential(t *testing.T) {
+	ds := makeSearchStore(t, 5000, 32)
+	defer ds.Close()
+	ctx := context.Background()
+	query := randomUnitVector(rand.New(rand.NewSource(2)), 32)
+
+	ds.searchWorkers = 1
+	want, err := ds.Search(ctx, query, 10)
+	assert.NoError(t, err)
+	assert.Len(t, want, 10)
+
+	ds.searchWorkers = 8
+	got, err := ds.Search(ctx, query, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	for i := 1; i < len(got); i++ {
+		assert.GreaterOrEqual(t, got[i-1].Score, got[i].Score)
+	}
+	best := got[0]
+	assert.Equal(t, best.Score, vector.FastCosineSimilarity(query, best.Document.Chunks[best.BestMatchingChunk].Embedding))
+}
+
+func TestSearchCancelled(t *testing.T) {
+	ds := makeSearchStore(t, 5000, 8)
+	defer ds.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := ds.Search(ctx, randomUnitVector(rand.New(rand.NewSource(2)), 8), 5)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func BenchmarkDiskStoreSearch(b *testing.B) {
+	ds := makeSearchStore(b, 20_000, 768)
+	defer ds.Close()
+	ctx := context.Background()
+	query := randomUnitVector(rand.New(rand.NewSource(2)), 768)
+
+	for _, workers := range []int{1, max(2, runtime.NumCPU())} {
+		b.Run(fmt.Sprintf("Workers-%d", workers), func(b *testing.B) {
+			ds.searchWorkers = workers
+			for i := 0; i < b.N; i++ {
+				ds.Search(ctx, query, 5)
+			}
+		})
+	}
+}
+
+func TestChunkTextPersists(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "store.skdb")
+	ctx := context.Background()
+
+	chunk := embeddings.Chunk{Embedding: []float32{1, 0}, Text: "hello\nworld", Start: 10, End: 21, StartLine: 2, EndLine: 3}
+	doc, err := document.NewDocument("doc", []embeddings.Chunk{chunk}, time.Now(), "path/example")
+	assert.NoError(t, err)
+
+	ds, err := NewDiskStore(file)
+	assert.NoError(t, err)
+	assert.NoError(t, ds.Index(ctx, doc))
+	assert.NoError(t, ds.Close())
+
+	// quantized stores keep the text of their chunks in memory
+	ds, err = NewDiskStore(file, WithQuantization(ScalarQuantization))
+	assert.NoError(t, err)
+	defer ds.Close()
+
+	results, err := ds.Search(ctx, []float32{1, 0}, 1)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	got := results[0].Document.Chunks[results[0].BestMatchingChunk]
+	chunk.Embedding = nil
+	assert.Equal(t, chunk, got)
+}
+
+func TestStoreRecordsEmbedding(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "store.skdb")
+	ctx := context.Background()
+
+	ds, err := NewDiskStore(file, WithEmbedding("all-minilm", 0))
+	assert.NoError(t, err)
+	doc, _ := document.NewDocument("a", []embeddings.Chunk{{Embedding: []float32{1, 0, 0}}}, time.Now(), "path/a")
+	assert.NoError(t, ds.Index(ctx, doc))
+
+	// the first document sets the dimension, which later ones must have
+	doc, _ = document.NewDocument("b", []embeddings.Chunk{{Embedding: []float32{1, 0}}}, time.Now(), "path/b")
+	assert.ErrorIs(t, ds.Index(ctx, doc), ErrEmbeddingMismatch)
+	_, err = ds.Search(ctx, []float32{1, 0}, 1)
+	assert.ErrorIs(t, err, ErrEmbeddingMismatch)
+	assert.NoError(t, ds.Close())
+
+	ds, err = NewDiskStore(file)
+	assert.NoError(t, err)
+	model, dimension := ds.Embedding()
+	assert.Equal(t, "all-minilm", model)
+	assert.Equal(t, 3, dimension)
+	assert.NoError(t, ds.Close())
+
+	_, err = NewDiskStore(file, WithEmbedding("mxbai-embed-large", 0))
+	assert.ErrorIs(t, err, ErrEmbeddingMismatch)
+	_, err = NewDiskStore(file, WithEmbedding("all-minilm", 384))
+	assert.ErrorIs(t, err, ErrEmbeddingMismatch)
+}
+
+func TestEmptyStoreSwitchesEmbedding(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "store.skdb")
+
+	ds, err := NewDiskStore(file, WithEmbedding("all-minilm", 384))
+	assert.NoError(t, err)
+	assert.NoError(t, ds.Close())
+
+	ds, err = NewDiskStore(file, WithEmbedding("mxbai-embed-large", 1024))
+	assert.NoError(t, err)
+	assert.NoError(t, ds.Close())
+
+	ds, err = NewDiskStore(file)
+	assert.NoError(t, err)
+	defer ds.Close()
+	model, dimension := ds.Embedding()
+	assert.Equal(t, "mxbai-embed-large", model)
+	assert.Equal(t, 1024, dimension)
+}
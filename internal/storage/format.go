@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// Every store file starts with a fixed-size header:
+//
+//	magic "SKDB" | version (2 bytes) | dimension (4 bytes) | model length (2 bytes) | model (zero padded) |
+//	quantization (1 byte) | index (1 byte) | crc32 (4 bytes)
+//
+// Files written by older versions are upgraded on open through the migrations registry.
+
+var fileMagic = [4]byte{'S', 'K', 'D', 'B'}
+
+const (
+	currentVersion  uint16 = 4
+	maxModelNameLen        = 256
+	fileHeaderSize         = len(fileMagic) + 2 + 4 + 2 + maxModelNameLen + 1 + 1 + 4
+)
+
+var ErrUnsupportedVersion = errors.New("unsupported store format version")
+
+// fileHeader describes the embeddings held by a store.
+type fileHeader struct {
+	Model     string
+	Dimension int
+	// Quantization is how the store holds embeddings in memory. The records always keep them in full.
+	Quantization Quantization
+	// Index is the vector index kept alongside the store and updated by every writer.
+	Index IndexKind
+}
+
+func writeFileHeader(w io.Writer, h fileHeader) error {
+	if len(h.Model) > maxModelNameLen {
+		return fmt.Errorf("model name is longer than %d bytes", maxModelNameLen)
+	}
+
+	buf := make([]byte, fileHeaderSize)
+	copy(buf, fileMagic[:])
+	offset := len(fileMagic)
+	binary.LittleEndian.PutUint16(buf[offset:], currentVersion)
+	offset += 2
+	binary.LittleEndian.PutUint32(buf[offset:], uint32(h.Dimension))
+	offset += 4
+	binary.LittleEndian.PutUint16(buf[offset:], uint16(len(h.Model)))
+	offset += 2
+	copy(buf[offset:], h.Model)
+	offset += maxModelNameLen
+	buf[offset] = h.Quantization.code()
+	offset++
+	buf[offset] = h.Index.code()
+	offset++
+	binary.LittleEndian.PutUint32(buf[offset:], crc32.ChecksumIEEE(buf[:offset]))
+
+	_, err := w.Write(buf)
+	return err
+}
+
+func readFileHeader(r io.Reader) (fileHeader, error) {
+	buf := make([]byte, fileHeaderSize)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return fileHeader{}, fmt.Errorf("failed to read file header: %w", err)
+	}
+
+	if !bytes.Equal(buf[:len(fileMagic)], fileMagic[:]) {
+		return fileHeader{}, errors.New("missing store file header")
+	}
+	offset := len(fileMagic)
+	if version := binary.LittleEndian.Uint16(buf[offset:]); version != currentVersion {
+		return fileHeader{}, fmt.Errorf("%w %d", ErrUnsupportedVersion, version)
+	}
+	offset += 2
+	dimension := binary.LittleEndian.Uint32(buf[offset:])
+	offset += 4
+	modelLen := int(binary.LittleEndian.Uint16(buf[offset:]))
+	offset += 2
+	if modelLen > maxModelNameLen {
+		return fileHeader{}, errors.New("corrupt file header: model name is too long")
+	}
+	model := string(buf[offset : offset+modelLen])
+	offset += maxModelNameLen
+	quantization, quantizationOK := quantizationFromCode(buf[offset])
+	offset++
+	index, indexOK := indexKindFromCode(buf[offset])
+	offset++
+	if crc32.ChecksumIEEE(buf[:offset]) != binary.LittleEndian.Uint32(buf[offset:]) {
+		return fileHeader{}, errors.New("corrupt file header: checksum mismatch")
+	}
+	if !quantizationOK {
+		return fileHeader{}, fmt.Errorf("corrupt file header: unknown quantization %d", buf[offset-2])
+	}
+	if !indexOK {
+		return fileHeader{}, fmt.Errorf("corrupt file header: unknown index %d", buf[offset-1])
+	}
+
+	return fileHeader{Model: model, Dimension: int(dimension), Quantization: quantization, Index: index}, nil
+}
+
+// detectVersion returns the format version of the store file data. Files without the magic
+// are the original gob snapshots (version 0); empty files need no migration.
+func detectVersion(data []byte) uint16 {
+	if len(data) == 0 {
+		return currentVersion
+	}
+	if len(data) < len(fileMagic)+2 || !bytes.Equal(data[:len(fileMagic)], fileMagic[:]) {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(data[len(fileMagic):])
+}
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jnaraujo/seekr/internal/document"
+)
+
+type CheckReport struct {
+	Model     string
+	Dimension int
+	// Records counts the valid records in the log, Documents the live documents they add up to.
+	Records   int
+	Documents int
+	Problems  []Problem
+	// Size is the size of the file and End the offset where its valid log ends.
+	// Anything in between was left by an append that never completed.
+	Size int64
+	End  int64
+}
+
+// OK reports whether the store has no problems at all.
+func (r CheckReport) OK() bool {
+	return len(r.Problems) == 0 && r.End == r.Size
+}
+
+// Check verifies every record of the store file at path, without modifying it. Stores written by
+// older versions are upgraded in memory first, like read-only stores are when opened.
+func Check(path string) (CheckReport, error) {
+	data, err := readUpgraded(path)
+	if err != nil {
+		return CheckReport{}, err
+	}
+	return check(data)
+}
+
+// readUpgraded returns the content of the store file at path in the current format version.
+func readUpgraded(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return upgrade(data)
+}
+
+func check(data []byte) (CheckReport, error) {
+	header, err := readFileHeader(bytes.NewReader(data))
+	if err != nil {
+		return CheckReport{}, err
+	}
+
+	report := CheckReport{Model: header.Model, Dimension: header.Dimension, Size: int64(len(data))}
+	live := make(map[string]struct{})
+	report.End, report.Problems = walkRecords(data[fileHeaderSize:], int64(fileHeaderSize), func(_ int64, rec record, _ int64) error {
+		switch rec.kind {
+		case recordAdd:
+			doc, err := decodeDocument(rec.payload)
+			if err != nil {
+				return fmt.Errorf("failed to decode document: %w", err)
+			}
+			if err := checkDocument(doc, header.Dimension); err != nil {
+				return err
+			}
+			live[doc.ID] = struct{}{}
+		case recordRemove:
+			delete(live, string(rec.payload))
+		}
+		report.Records++
+		return nil
+	})
+	report.Documents = len(live)
+
+	return report, nil
+}
+
+func checkDocument(doc document.Document, dimension int) error {
+	if doc.ID == "" {
+		return errors.New("document has no id")
+	}
+	for i, chunk := range doc.Chunks {
+		if dimension > 0 && len(chunk.Embedding) != dimension {
+			return fmt.Errorf("document %q: chunk %d has %d dimensions, expected %d",
+				doc.ID, i, len(chunk.Embedding), dimension)
+		}
+	}
+	return nil
+}
+
+// QuarantinePath returns the file where Quarantine moves the bad records of the store at path.
+func QuarantinePath(path string) string {
+	return path + ".quarantine"
+}
+
+// Quarantine checks the store file at path and moves every bad record, including an incomplete
+// trailing one, to the quarantine file next to it. The store is rewritten without them, in the
+// current format version. The returned report describes the store before it was repaired.
+func Quarantine(path string) (CheckReport, error) {
+	data, err := readUpgraded(path)
+	if err != nil {
+		return CheckReport{}, err
+	}
+
+	report, err := check(data)
+	if err != nil || report.OK() {
+		return report, err
+	}
+
+	bad := report.Problems
+	if report.End < report.Size {
+		bad = append(bad, Problem{Offset: report.End, Size: report.Size - report.End})
+	}
+
+	q, err := os.OpenFile(QuarantinePath(path), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return report, fmt.Errorf("failed to open quarantine file: %w", err)
+	}
+	for _, p := range bad {
+		if _, err := q.Write(data[p.Offset : p.Offset+p.Size]); err != nil {
+			q.Close()
+			return report, fmt.Errorf("failed to write quarantine file: %w", err)
+		}
+	}
+	if err := q.Sync(); err != nil {
+		q.Close()
+		return report, fmt.Errorf("failed to sync quarantine file: %w", err)
+	}
+	if err := q.Close(); err != nil {
+		return report, fmt.Errorf("failed to close quarantine file: %w", err)
+	}
+
+	err = writeFileAtomic(path, func(w *bufio.Writer) error {
+		var pos int64
+		for _, p := range bad {
+			if _, err := w.Write(data[pos:p.Offset]); err != nil {
+				return err
+			}
+			pos = p.Offset + p.Size
+		}
+		_, err := w.Write(data[pos:])
+		return err
+	})
+	if err != nil {
+		return report, fmt.Errorf("failed to rewrite store: %w", err)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jnaraujo/seekr/internal/config"
+	"github.com/jnaraujo/seekr/internal/document"
+	"github.com/jnaraujo/seekr/internal/embeddings"
+	"github.com/stretchr/testify/assert"
+)
+
+func makeValidDocument(t *testing.T, id string) document.Document {
+	t.Helper()
+	embedding := make([]float32, config.EmbeddingDimension)
+	embedding[0] = 1
+	doc, err := document.NewDocument(id, []embeddings.Chunk{{Embedding: embedding}}, time.Now(), "path/"+id)
+	assert.NoError(t, err)
+	return doc
+}
+
+// makeCorruptStore writes three documents and flips a byte inside the second one.
+func makeCorruptStore(t *testing.T) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "store.skdb")
+
+	ds, err := NewDiskStore(file)
+	assert.NoError(t, err)
+	assert.NoError(t, ds.Index(context.Background(), makeValidDocument(t, "a")))
+	secondOffset := ds.size
+	assert.NoError(t, ds.Index(context.Background(), makeValidDocument(t, "b")))
+	assert.NoError(t, ds.Index(context.Background(), makeValidDocument(t, "c")))
+	assert.NoError(t, ds.Close())
+
+	data, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	data[secondOffset+recordHeaderSize+10] ^= 0xff
+	assert.NoError(t, os.WriteFile(file, data, 0o644))
+
+	return file
+}
+
+func TestCheckHealthyStore(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "store.skdb")
+	ds, err := NewDiskStore(file)
+	assert.NoError(t, err)
+	assert.NoError(t, ds.Index(context.Background(), makeValidDocument(t, "a")))
+	assert.NoError(t, ds.Index(context.Background(), makeValidDocument(t, "b")))
+	assert.NoError(t, ds.Remove(context.Background(), "a"))
+	assert.NoError(t, ds.Close())
+
+	report, err := Check(file)
+	assert.NoError(t, err)
+	assert.True(t, report.OK())
+	assert.Equal(t, 3, report.Records)
+	assert.Equal(t, 1, report.Documents)
+}
+
+func TestCheckOlderVersion(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "store.skdb")
+	ds, err := NewDiskStore(file)
+	assert.NoError(t, err)
+	assert.NoError(t, ds.Index(context.Background(), makeValidDocument(t, "a")))
+	header := ds.header
+	assert.NoError(t, ds.Close())
+
+	// rewrite the store in the version 3 layout
+	data, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	var old bytes.Buffer
+	assert.NoError(t, writeV3Header(&old, header))
+	old.Write(data[fileHeaderSize:])
+	assert.NoError(t, os.WriteFile(file, old.Bytes(), 0o644))
+
+	report, err := Check(file)
+	assert.NoError(t, err)
+	assert.True(t, report.OK())
+	assert.Equal(t, 1, report.Documents)
+	assert.Equal(t, header.Model, report.Model)
+
+	// checking leaves the file as it was
+	data, err = os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, old.Bytes(), data)
+}
+
+func TestLoadCorruptStore(t *testing.T) {
+	file := makeCorruptStore(t)
+
+	_, err := NewDiskStore(file)
+	assert.ErrorIs(t, err, ErrCorrupt)
+
+	ds, err := NewDiskStore(file, WithSkipCorrupt())
+	assert.NoError(t, err)
+	defer ds.Close()
+
+	docs, err := ds.List(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, docs, 2)
+	assert.Equal(t, "a", docs[0].ID)
+	assert.Equal(t, "c", docs[1].ID)
+	assert.Len(t, ds.Problems(), 1)
+}
+
+func TestCheckReportsWrongDimension(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "store.skdb")
+	ds, err := NewDiskStore(file)
+	assert.NoError(t, err)
+	assert.NoError(t, ds.Index(context.Background(), makeValidDocument(t, "a")))
+	assert.NoError(t, ds.Close())
+
+	// the store refuses such documents, so the record is appended as older versions wrote it
+	doc, _ := document.NewDocument("short", []embeddings.Chunk{{Embedding: []float32{1, 0}}}, time.Now(), "path/short")
+	rec, err := encodeAddRecord(doc)
+	assert.NoError(t, err)
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0)
+	assert.NoError(t, err)
+	_, err = f.Write(rec)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	report, err := Check(file)
+	assert.NoError(t, err)
+	assert.False(t, report.OK())
+	assert.Len(t, report.Problems, 1)
+	assert.Contains(t, report.Problems[0].Reason, "dimensions")
+}
+
+func TestQuarantine(t *testing.T) {
+	file := makeCorruptStore(t)
+
+	report, err := Quarantine(file)
+	assert.NoError(t, err)
+	assert.Len(t, report.Problems, 1)
+
+	quarantined, err := os.ReadFile(QuarantinePath(file))
+	assert.NoError(t, err)
+	assert.Equal(t, report.Problems[0].Size, int64(len(quarantined)))
+
+	report, err = Check(file)
+	assert.NoError(t, err)
+	assert.True(t, report.OK())
+	assert.Equal(t, 2, report.Documents)
+
+	ds, err := NewDiskStore(file)
+	assert.NoError(t, err)
+	defer ds.Close()
+	docs, err := ds.List(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, docs, 2)
+}
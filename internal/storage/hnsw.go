@@ -0,0 +1,492 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jnaraujo/seekr/internal/document"
+	"github.com/jnaraujo/seekr/internal/vector"
+)
+
+// HNSWParams configures the graph built by the HNSW index.
+type HNSWParams struct {
+	// M is the number of links each node keeps per layer (twice as many on the bottom layer).
+	M int
+	// EfConstruction is the size of the candidate list used while inserting nodes.
+	EfConstruction int
+	// EfSearch is the size of the candidate list used while searching. Larger is slower but more accurate.
+	EfSearch int
+}
+
+func DefaultHNSWParams() HNSWParams {
+	return HNSWParams{M: 16, EfConstruction: 200, EfSearch: 64}
+}
+
+const hnswFileVersion = 1
+
+// hnswPath returns the file where the HNSW index of the store at storePath is saved.
+func hnswPath(storePath string) string {
+	return storePath + ".hnsw"
+}
+
+type hnswNode struct {
+	Ref     chunkRef
+	Links   [][]int32
+	Deleted bool
+	// vec points into the embedding of the document chunk; it is not saved with the graph.
+	// Nodes loaded from disk whose document is gone have no vector and are skipped while searching.
+	vec []float32
+}
+
+type hnswDoc struct {
+	CreatedAt time.Time
+	Nodes     []int32
+}
+
+// hnswGraph implements vectorIndex with a hierarchical navigable small world graph
+// (Malkov & Yashunin, https://arxiv.org/abs/1603.09320) using cosine similarity on
+// normalized vectors. Removed chunks stay in the graph as deleted nodes so searches can
+// still route through them, until enough pile up to rebuild the graph.
+type hnswGraph struct {
+	params   HNSWParams
+	nodes    []*hnswNode
+	entry    int32
+	maxLevel int
+	docs     map[string]hnswDoc
+	deleted  int
+	changed  bool
+
+	levelMult float64
+	rng       *rand.Rand
+}
+
+var _ vectorIndex = &hnswGraph{}
+
+// hnswFile is the on-disk representation of a graph.
+type hnswFile struct {
+	Version        int
+	M              int
+	EfConstruction int
+	Nodes          []hnswNode
+	Entry          int32
+	MaxLevel       int
+	Docs           map[string]hnswDoc
+}
+
+func newHNSWGraph(params HNSWParams) *hnswGraph {
+	defaults := DefaultHNSWParams()
+	if params.M < 2 {
+		params.M = defaults.M
+	}
+	if params.EfConstruction <= 0 {
+		params.EfConstruction = defaults.EfConstruction
+	}
+	if params.EfSearch <= 0 {
+		params.EfSearch = defaults.EfSearch
+	}
+
+	return &hnswGraph{
+		params:    params,
+		entry:     -1,
+		docs:      make(map[string]hnswDoc),
+		levelMult: 1 / math.Log(float64(params.M)),
+		rng:       rand.New(rand.NewSource(1)),
+	}
+}
+
+// loadHNSWGraph reads the graph saved at path. A missing, unreadable or outdated file, or one
+// built with other parameters, yields an empty graph that sync fills from the documents.
+func loadHNSWGraph(path string, params HNSWParams, docs []document.Document) *hnswGraph {
+	g := newHNSWGraph(params)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return g
+	}
+	defer f.Close()
+
+	var file hnswFile
+	if err := gob.NewDecoder(bufio.NewReader(f)).Decode(&file); err != nil {
+		return g
+	}
+	if file.Version != hnswFileVersion || file.M != g.params.M || file.EfConstruction != g.params.EfConstruction {
+		return g
+	}
+
+	byID := make(map[string]document.Document, len(docs))
+	for _, doc := range docs {
+		byID[doc.ID] = doc
+	}
+
+	g.nodes = make([]*hnswNode, len(file.Nodes))
+	for i := range file.Nodes {
+		node := &file.Nodes[i]
+		if doc, ok := byID[node.Ref.DocID]; ok && node.Ref.Chunk < len(doc.Chunks) {
+			node.vec = doc.Chunks[node.Ref.Chunk].Embedding
+		}
+		if node.Deleted {
+			g.deleted++
+		}
+		g.nodes[i] = node
+	}
+	g.entry = file.Entry
+	g.maxLevel = file.MaxLevel
+	g.docs = file.Docs
+	if g.docs == nil {
+		g.docs = make(map[string]hnswDoc)
+	}
+
+	if g.entry >= int32(len(g.nodes)) || (g.entry >= 0 && g.nodes[g.entry].vec == nil) {
+		return newHNSWGraph(params)
+	}
+	return g
+}
+
+func (g *hnswGraph) save(path string) error {
+	file := hnswFile{
+		Version:        hnswFileVersion,
+		M:              g.params.M,
+		EfConstruction: g.params.EfConstruction,
+		Nodes:          make([]hnswNode, len(g.nodes)),
+		Entry:          g.entry,
+		MaxLevel:       g.maxLevel,
+		Docs:           g.docs,
+	}
+	for i, node := range g.nodes {
+		file.Nodes[i] = *node
+	}
+
+	err := writeFileAtomic(path, func(w *bufio.Writer) error {
+		return gob.NewEncoder(w).Encode(file)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save hnsw index: %w", err)
+	}
+	g.changed = false
+	return nil
+}
+
+func (g *hnswGraph) dirty() bool {
+	return g.changed
+}
+
+func (g *hnswGraph) sync(docs []document.Document) {
+	indexed := make(map[string]time.Time, len(g.docs))
+	for id, entry := range g.docs {
+		indexed[id] = entry.CreatedAt
+	}
+	syncDocs(g, indexed, docs)
+}
+
+func (g *hnswGraph) build(docs []document.Document) {
+	*g = *newHNSWGraph(g.params)
+	for _, doc := range docs {
+		g.add(doc)
+	}
+	g.changed = true
+}
+
+func (g *hnswGraph) ready() bool {
+	return true
+}
+
+func (g *hnswGraph) add(doc document.Document) {
+	if _, ok := g.docs[doc.ID]; ok {
+		g.remove(doc.ID)
+	}
+
+	entry := hnswDoc{CreatedAt: doc.CreatedAt, Nodes: make([]int32, 0, len(doc.Chunks))}
+	for i, chunk := range doc.Chunks {
+		if len(chunk.Embedding) == 0 {
+			continue
+		}
+		entry.Nodes = append(entry.Nodes, g.insert(chunkRef{DocID: doc.ID, Chunk: i}, chunk.Embedding))
+	}
+	g.docs[doc.ID] = entry
+	g.changed = true
+}
+
+func (g *hnswGraph) remove(id string) {
+	entry, ok := g.docs[id]
+	if !ok {
+		return
+	}
+
+	for _, n := range entry.Nodes {
+		if !g.nodes[n].Deleted {
+			g.nodes[n].Deleted = true
+			g.deleted++
+		}
+	}
+	delete(g.docs, id)
+	g.changed = true
+
+	if g.deleted > len(g.nodes)/2 {
+		g.rebuild()
+	}
+}
+
+// rebuild inserts the live nodes into a fresh graph, dropping the deleted ones.
+func (g *hnswGraph) rebuild() {
+	old := g.nodes
+	docs := g.docs
+
+	g.nodes = nil
+	g.entry = -1
+	g.maxLevel = 0
+	g.deleted = 0
+	g.docs = make(map[string]hnswDoc, len(docs))
+
+	for id, entry := range docs {
+		nodes := make([]int32, 0, len(entry.Nodes))
+		for _, n := range entry.Nodes {
+			if node := old[n]; node.vec != nil {
+				nodes = append(nodes, g.insert(node.Ref, node.vec))
+			}
+		}
+		g.docs[id] = hnswDoc{CreatedAt: entry.CreatedAt, Nodes: nodes}
+	}
+	g.changed = true
+}
+
+func (g *hnswGraph) search(ctx context.Context, query []float32, k int) ([]chunkHit, error) {
+	if g.entry < 0 || k <= 0 {
+		return nil, nil
+	}
+
+	ep := g.descend(query, g.entry, g.maxLevel, 0)
+	found := g.searchLayer(ctx, query, []int32{ep}, max(g.params.EfSearch, k), 0)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	hits := make([]chunkHit, 0, min(k, len(found)))
+	for _, c := range found {
+		node := g.nodes[c.id]
+		if node.Deleted {
+			continue
+		}
+		hits = append(hits, chunkHit{ref: node.Ref, score: c.score})
+		if len(hits) == k {
+			break
+		}
+	}
+	return hits, nil
+}
+
+func (g *hnswGraph) randomLevel() int {
+	return int(math.Floor(-math.Log(1-g.rng.Float64()) * g.levelMult))
+}
+
+func (g *hnswGraph) maxLinks(level int) int {
+	if level == 0 {
+		return 2 * g.params.M
+	}
+	return g.params.M
+}
+
+// insert adds a node for vec to the graph and returns its ID.
+func (g *hnswGraph) insert(ref chunkRef, vec []float32) int32 {
+	id := int32(len(g.nodes))
+	level := g.randomLevel()
+	node := &hnswNode{Ref: ref, Links: make([][]int32, level+1), vec: vec}
+	g.nodes = append(g.nodes, node)
+
+	if g.entry < 0 {
+		g.entry = id
+		g.maxLevel = level
+		return id
+	}
+
+	ep := []int32{g.descend(vec, g.entry, g.maxLevel, level)}
+	for l := min(level, g.maxLevel); l >= 0; l-- {
+		found := g.searchLayer(context.Background(), vec, ep, g.params.EfConstruction, l)
+		neighbours := g.selectNeighbours(found, g.params.M)
+
+		node.Links[l] = make([]int32, 0, len(neighbours))
+		for _, nb := range neighbours {
+			node.Links[l] = append(node.Links[l], nb.id)
+
+			other := g.nodes[nb.id]
+			other.Links[l] = append(other.Links[l], id)
+			if len(other.Links[l]) > g.maxLinks(l) {
+				g.shrink(other, l)
+			}
+		}
+
+		ep = ep[:0]
+		for _, c := range found {
+			ep = append(ep, c.id)
+		}
+	}
+
+	if level > g.maxLevel {
+		g.maxLevel = level
+		g.entry = id
+	}
+	return id
+}
+
+// descend greedily walks from the entry point down to the given level, returning the closest node found.
+func (g *hnswGraph) descend(query []float32, ep int32, from, to int) int32 {
+	for l := from; l > to; l-- {
+		if found := g.searchLayer(context.Background(), query, []int32{ep}, 1, l); len(found) > 0 {
+			ep = found[0].id
+		}
+	}
+	return ep
+}
+
+// shrink keeps only the closest links of node on the given level.
+func (g *hnswGraph) shrink(node *hnswNode, level int) {
+	candidates := make([]candidate, 0, len(node.Links[level]))
+	for _, id := range node.Links[level] {
+		if other := g.nodes[id]; other.vec != nil {
+			candidates = append(candidates, candidate{id: id, score: vector.FastCosineSimilarity(node.vec, other.vec)})
+		}
+	}
+	slices.SortFunc(candidates, compareCandidates)
+
+	// unlike on insertion, the closest links are kept without the diversity heuristic:
+	// this runs for every overflowing neighbour and the heuristic is quadratic in the links
+	node.Links[level] = node.Links[level][:0]
+	for _, c := range candidates[:min(len(candidates), g.maxLinks(level))] {
+		node.Links[level] = append(node.Links[level], c.id)
+	}
+}
+
+// selectNeighbours picks up to m neighbours out of candidates (sorted best first), preferring
+// candidates that are closer to the new node than to the neighbours already picked so that
+// links spread in different directions. Pruned candidates fill any remaining slots.
+func (g *hnswGraph) selectNeighbours(candidates []candidate, m int) []candidate {
+	if len(candidates) <= m {
+		return candidates
+	}
+
+	selected := make([]candidate, 0, m)
+	var pruned []candidate
+	for _, c := range candidates {
+		if len(selected) == m {
+			break
+		}
+		vec := g.nodes[c.id].vec
+		diverse := true
+		for _, s := range selected {
+			if vector.FastCosineSimilarity(vec, g.nodes[s.id].vec) > c.score {
+				diverse = false
+				break
+			}
+		}
+		if diverse {
+			selected = append(selected, c)
+		} else {
+			pruned = append(pruned, c)
+		}
+	}
+	for _, c := range pruned {
+		if len(selected) == m {
+			break
+		}
+		selected = append(selected, c)
+	}
+	return selected
+}
+
+// searchLayer returns up to ef nodes of the given layer closest to the query, best first.
+// It stops early when ctx is cancelled, returning the nodes found so far.
+func (g *hnswGraph) searchLayer(ctx context.Context, query []float32, entries []int32, ef, level int) []candidate {
+	visited := visitedPool.Get().(*visitedList)
+	defer visitedPool.Put(visited)
+	visited.reset(len(g.nodes))
+
+	candidates := &candidateHeap{best: true}
+	results := &candidateHeap{items: make([]candidate, 0, ef+1)}
+
+	for _, id := range entries {
+		node := g.nodes[id]
+		if node.vec == nil || visited.visit(id) {
+			continue
+		}
+		c := candidate{id: id, score: vector.FastCosineSimilarity(query, node.vec)}
+		candidates.push(c)
+		results.push(c)
+		if results.len() > ef {
+			results.pop()
+		}
+	}
+
+	for expanded := 0; candidates.len() > 0; expanded++ {
+		if expanded%cancelCheckInterval == 0 && ctx.Err() != nil {
+			break
+		}
+		c := candidates.pop()
+		if results.len() >= ef && c.score < results.top().score {
+			break
+		}
+
+		links := g.nodes[c.id].Links
+		if level >= len(links) {
+			continue
+		}
+		for _, id := range links[level] {
+			if visited.visit(id) {
+				continue
+			}
+
+			node := g.nodes[id]
+			if node.vec == nil {
+				continue
+			}
+			score := vector.FastCosineSimilarity(query, node.vec)
+			if results.len() < ef || score > results.top().score {
+				candidates.push(candidate{id: id, score: score})
+				results.push(candidate{id: id, score: score})
+				if results.len() > ef {
+					results.pop()
+				}
+			}
+		}
+	}
+
+	found := results.items
+	slices.SortFunc(found, compareCandidates)
+	return found
+}
+
+// visitedPool recycles the visited marks of searches. Marks are stamped with a generation
+// number, so a list does not need clearing before it is reused.
+var visitedPool = sync.Pool{New: func() any { return &visitedList{} }}
+
+type visitedList struct {
+	marks []uint32
+	gen   uint32
+}
+
+func (v *visitedList) reset(n int) {
+	if len(v.marks) < n {
+		v.marks = make([]uint32, n+n/4)
+		v.gen = 0
+	}
+	v.gen++
+	if v.gen == 0 {
+		clear(v.marks)
+		v.gen = 1
+	}
+}
+
+// visit marks id as visited and reports whether it already was.
+func (v *visitedList) visit(id int32) bool {
+	if v.marks[id] == v.gen {
+		return true
+	}
+	v.marks[id] = v.gen
+	return false
+}
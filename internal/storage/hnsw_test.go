@@ -0,0 +1,260 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jnaraujo/seekr/internal/document"
+	"github.com/jnaraujo/seekr/internal/embeddings"
+	"github.com/jnaraujo/seekr/internal/vector"
+	"github.com/stretchr/testify/assert"
+)
+
+func randomUnitVector(rng *rand.Rand, dim int) []float32 {
+	vec := make([]float32, dim)
+	for i := range vec {
+		vec[i] = float32(rng.NormFloat64())
+	}
+	return vector.Normalize(vec)
+}
+
+// makeRandomDocuments returns n single-chunk documents with random embeddings.
+func makeRandomDocuments(rng *rand.Rand, n, dim int) []document.Document {
+	docs := make([]document.Document, n)
+	for i := range docs {
+		docs[i], _ = document.NewDocument(fmt.Sprintf("doc-%d", i), []embeddings.Chunk{{
+			Embedding: randomUnitVector(rng, dim),
+		}}, time.Now(), fmt.Sprintf("path/%d", i))
+	}
+	return docs
+}
+
+func bruteForceTopK(docs []document.Document, query []float32, k int) []string {
+	type scored struct {
+		id    string
+		score float32
+	}
+	all := make([]scored, len(docs))
+	for i, doc := range docs {
+		all[i] = scored{doc.ID, vector.FastCosineSimilarity(query, doc.Chunks[0].Embedding)}
+	}
+	slices.SortFunc(all, func(a, b scored) int { return cmp.Compare(b.score, a.score) })
+
+	ids := make([]string, k)
+	for i := range ids {
+		ids[i] = all[i].id
+	}
+	return ids
+}
+
+// searchHits returns the k chunks idx finds closest to query.
+func searchHits(t *testing.T, idx vectorIndex, query []float32, k int) []chunkHit {
+	t.Helper()
+	hits, err := idx.search(context.Background(), query, k)
+	assert.NoError(t, err)
+	return hits
+}
+
+// indexRecall returns the average fraction of the true top k found by the index.
+func indexRecall(idx vectorIndex, docs []document.Document, queries [][]float32, k int) float64 {
+	var found int
+	for _, q := range queries {
+		want := bruteForceTopK(docs, q, k)
+		got := make(map[string]bool)
+		hits, _ := idx.search(context.Background(), q, k)
+		for _, hit := range hits {
+			got[hit.ref.DocID] = true
+		}
+		for _, id := range want {
+			if got[id] {
+				found++
+			}
+		}
+	}
+	return float64(found) / float64(len(queries)*k)
+}
+
+func TestHNSWRecall(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	docs := makeRandomDocuments(rng, 2000, 32)
+	queries := make([][]float32, 50)
+	for i := range queries {
+		queries[i] = randomUnitVector(rng, 32)
+	}
+
+	g := newHNSWGraph(DefaultHNSWParams())
+	for _, doc := range docs {
+		g.add(doc)
+	}
+
+	recall := indexRecall(g, docs, queries, 10)
+	assert.GreaterOrEqual(t, recall, 0.9)
+}
+
+func TestHNSWRemove(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	docs := makeRandomDocuments(rng, 200, 16)
+
+	g := newHNSWGraph(DefaultHNSWParams())
+	for _, doc := range docs {
+		g.add(doc)
+	}
+
+	target := docs[7]
+	hits := searchHits(t, g, target.Chunks[0].Embedding, 1)
+	assert.Equal(t, target.ID, hits[0].ref.DocID)
+
+	g.remove(target.ID)
+	for _, hit := range searchHits(t, g, target.Chunks[0].Embedding, 10) {
+		assert.NotEqual(t, target.ID, hit.ref.DocID)
+	}
+
+	// removing most documents rebuilds the graph without them
+	for _, doc := range docs[:150] {
+		g.remove(doc.ID)
+	}
+	assert.Less(t, len(g.nodes), 200)
+	hits = searchHits(t, g, docs[180].Chunks[0].Embedding, 1)
+	assert.Equal(t, docs[180].ID, hits[0].ref.DocID)
+}
+
+func TestHNSWStorePersistsIndex(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "store.skdb")
+	ctx := context.Background()
+	rng := rand.New(rand.NewSource(7))
+	docs := makeRandomDocuments(rng, 100, 16)
+
+	ds, err := NewDiskStore(file, WithIndex(HNSWIndex))
+	assert.NoError(t, err)
+	for _, doc := range docs {
+		assert.NoError(t, ds.Index(ctx, doc))
+	}
+	assert.NoError(t, ds.Close())
+
+	_, err = os.Stat(hnswPath(file))
+	assert.NoError(t, err)
+
+	// the store records its index, which writers opened without asking for it keep up to date
+	plain, err := NewDiskStore(file)
+	assert.NoError(t, err)
+	assert.Equal(t, HNSWIndex, plain.VectorIndex())
+	assert.NoError(t, plain.Remove(ctx, docs[0].ID))
+	assert.NoError(t, plain.Close())
+
+	saved := loadHNSWGraph(hnswPath(file), DefaultHNSWParams(), docs)
+	assert.NotContains(t, saved.docs, docs[0].ID)
+	assert.Contains(t, saved.docs, docs[1].ID)
+
+	ds, err = NewDiskStore(file)
+	assert.NoError(t, err)
+	defer ds.Close()
+
+	results, err := ds.Search(ctx, docs[0].Chunks[0].Embedding, 3)
+	assert.NoError(t, err)
+	for _, res := range results {
+		assert.NotEqual(t, docs[0].ID, res.Document.ID)
+	}
+
+	results, err = ds.Search(ctx, docs[1].Chunks[0].Embedding, 3)
+	assert.NoError(t, err)
+	assert.Len(t, results, 3)
+	assert.Equal(t, docs[1].ID, results[0].Document.ID)
+}
+
+func TestHNSWSearchStopsWhenCancelled(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	g := newHNSWGraph(DefaultHNSWParams())
+	for _, doc := range makeRandomDocuments(rng, 50, 8) {
+		g.add(doc)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := g.search(ctx, randomUnitVector(rng, 8), 5)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestStoreRefusesAnotherIndex(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "store.skdb")
+
+	ds, err := NewDiskStore(file, WithIndex(HNSWIndex))
+	assert.NoError(t, err)
+	assert.NoError(t, ds.Close())
+
+	_, err = NewDiskStore(file, WithIndex(IVFIndex))
+	assert.ErrorIs(t, err, ErrIndexMismatch)
+	_, err = NewDiskStore(file, WithIndex(BruteForceIndex))
+	assert.ErrorIs(t, err, ErrIndexMismatch)
+
+	ds, err = NewDiskStore(file, WithIndex(IVFIndex), WithIndexReplacement())
+	assert.NoError(t, err)
+	assert.NoError(t, ds.Close())
+
+	ds, err = NewDiskStore(file)
+	assert.NoError(t, err)
+	defer ds.Close()
+	assert.Equal(t, IVFIndex, ds.VectorIndex())
+}
+
+func BenchmarkSearch(b *testing.B) {
+	rng := rand.New(rand.NewSource(42))
+	docs := makeRandomDocuments(rng, 10_000, 64)
+	queries := make([][]float32, 100)
+	for i := range queries {
+		queries[i] = randomUnitVector(rng, 64)
+	}
+
+	// the stores search as NewDiskStore sets them up, so brute force scans over every CPU
+	newStore := func(index vectorIndex) *DiskStore {
+		return &DiskStore{
+			header:        fileHeader{Dimension: 64, Quantization: NoQuantization},
+			documents:     docs,
+			index:         index,
+			searchWorkers: runtime.NumCPU(),
+		}
+	}
+
+	b.Run("BruteForce", func(b *testing.B) {
+		ds := newStore(nil)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			ds.Search(context.Background(), queries[i%len(queries)], 10)
+		}
+	})
+
+	g := newHNSWGraph(DefaultHNSWParams())
+	for _, doc := range docs {
+		g.add(doc)
+	}
+
+	b.Run("HNSW", func(b *testing.B) {
+		ds := newStore(g)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			ds.Search(context.Background(), queries[i%len(queries)], 10)
+		}
+		b.StopTimer()
+		b.ReportMetric(indexRecall(g, docs, queries, 10), "recall@10")
+	})
+
+	ivf := newIVFIndex(DefaultIVFParams())
+	ivf.build(docs)
+
+	b.Run("IVF", func(b *testing.B) {
+		ds := newStore(ivf)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			ds.Search(context.Background(), queries[i%len(queries)], 10)
+		}
+		b.StopTimer()
+		b.ReportMetric(indexRecall(ivf, docs, queries, 10), "recall@10")
+	})
+}
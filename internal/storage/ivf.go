@@ -0,0 +1,340 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/jnaraujo/seekr/internal/document"
+	"github.com/jnaraujo/seekr/internal/vector"
+)
+
+// IVFParams configures the inverted file index.
+type IVFParams struct {
+	// NList is the number of clusters the chunks are split into. 0 uses the square root of the number of chunks.
+	NList int
+	// NProbe is the number of clusters closest to the query that a search scans.
+	NProbe int
+	// MinChunks is the number of chunks below which the index is not trained and searches scan every chunk.
+	MinChunks int
+}
+
+func DefaultIVFParams() IVFParams {
+	return IVFParams{NList: 0, NProbe: 16, MinChunks: 2048}
+}
+
+const (
+	ivfFileVersion = 1
+	// k-means is trained on a sample of at most this many chunks per cluster
+	ivfTrainSamplesPerList = 64
+	ivfTrainIterations     = 20
+)
+
+// ivfPath returns the file where the IVF index of the store at storePath is saved.
+func ivfPath(storePath string) string {
+	return storePath + ".ivf"
+}
+
+type ivfEntry struct {
+	ref chunkRef
+	vec []float32
+}
+
+type ivfDoc struct {
+	CreatedAt time.Time
+	// Lists holds the clusters with chunks of the document, -1 standing for the pending list.
+	Lists []int32
+}
+
+// ivfIndex implements vectorIndex with an inverted file: k-means centroids partition the chunks
+// into lists, and a search only scans the lists of the centroids closest to the query. Chunks
+// added before there are enough of them to train the centroids wait in a pending list.
+type ivfIndex struct {
+	params    IVFParams
+	centroids [][]float32
+	lists     [][]ivfEntry
+	pending   []ivfEntry
+	docs      map[string]ivfDoc
+	size      int
+	changed   bool
+	rng       *rand.Rand
+}
+
+var _ vectorIndex = &ivfIndex{}
+
+// ivfFile is the on-disk representation of the index. Vectors are not saved, they are
+// taken from the documents when the index is loaded.
+type ivfFile struct {
+	Version   int
+	Centroids [][]float32
+	Lists     [][]chunkRef
+	Pending   []chunkRef
+	Docs      map[string]ivfDoc
+}
+
+func newIVFIndex(params IVFParams) *ivfIndex {
+	defaults := DefaultIVFParams()
+	if params.NProbe <= 0 {
+		params.NProbe = defaults.NProbe
+	}
+	if params.MinChunks <= 0 {
+		params.MinChunks = defaults.MinChunks
+	}
+
+	return &ivfIndex{
+		params: params,
+		docs:   make(map[string]ivfDoc),
+		rng:    rand.New(rand.NewSource(1)),
+	}
+}
+
+// loadIVFIndex reads the index saved at path. A missing or unreadable file, or one trained
+// with another number of lists, yields an empty index that sync fills from the documents.
+func loadIVFIndex(path string, params IVFParams, docs []document.Document) *ivfIndex {
+	idx := newIVFIndex(params)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return idx
+	}
+	defer f.Close()
+
+	var file ivfFile
+	if err := gob.NewDecoder(bufio.NewReader(f)).Decode(&file); err != nil {
+		return idx
+	}
+	if file.Version != ivfFileVersion || (params.NList > 0 && len(file.Centroids) > 0 && params.NList != len(file.Centroids)) {
+		return idx
+	}
+
+	byID := make(map[string]document.Document, len(docs))
+	for _, doc := range docs {
+		byID[doc.ID] = doc
+	}
+	entries := func(refs []chunkRef) []ivfEntry {
+		res := make([]ivfEntry, 0, len(refs))
+		for _, ref := range refs {
+			if doc, ok := byID[ref.DocID]; ok && ref.Chunk < len(doc.Chunks) {
+				res = append(res, ivfEntry{ref: ref, vec: doc.Chunks[ref.Chunk].Embedding})
+			}
+		}
+		idx.size += len(res)
+		return res
+	}
+
+	idx.centroids = file.Centroids
+	idx.lists = make([][]ivfEntry, len(file.Lists))
+	for i, refs := range file.Lists {
+		idx.lists[i] = entries(refs)
+	}
+	idx.pending = entries(file.Pending)
+	if file.Docs != nil {
+		idx.docs = file.Docs
+	}
+	return idx
+}
+
+func (idx *ivfIndex) save(path string) error {
+	refs := func(entries []ivfEntry) []chunkRef {
+		res := make([]chunkRef, len(entries))
+		for i, e := range entries {
+			res[i] = e.ref
+		}
+		return res
+	}
+
+	file := ivfFile{
+		Version:   ivfFileVersion,
+		Centroids: idx.centroids,
+		Lists:     make([][]chunkRef, len(idx.lists)),
+		Pending:   refs(idx.pending),
+		Docs:      idx.docs,
+	}
+	for i, list := range idx.lists {
+		file.Lists[i] = refs(list)
+	}
+
+	err := writeFileAtomic(path, func(w *bufio.Writer) error {
+		return gob.NewEncoder(w).Encode(file)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save ivf index: %w", err)
+	}
+	idx.changed = false
+	return nil
+}
+
+func (idx *ivfIndex) dirty() bool {
+	return idx.changed
+}
+
+func (idx *ivfIndex) trained() bool {
+	return len(idx.centroids) > 0
+}
+
+func (idx *ivfIndex) ready() bool {
+	return idx.trained() && idx.size >= idx.params.MinChunks
+}
+
+func (idx *ivfIndex) sync(docs []document.Document) {
+	indexed := make(map[string]time.Time, len(idx.docs))
+	for id, entry := range idx.docs {
+		indexed[id] = entry.CreatedAt
+	}
+	syncDocs(idx, indexed, docs)
+
+	if !idx.trained() && idx.size >= idx.params.MinChunks {
+		idx.train()
+	}
+}
+
+func (idx *ivfIndex) build(docs []document.Document) {
+	*idx = *newIVFIndex(idx.params)
+	for _, doc := range docs {
+		idx.add(doc)
+	}
+	if idx.size >= idx.params.MinChunks {
+		idx.train()
+	}
+	idx.changed = true
+}
+
+func (idx *ivfIndex) add(doc document.Document) {
+	if _, ok := idx.docs[doc.ID]; ok {
+		idx.remove(doc.ID)
+	}
+
+	entry := ivfDoc{CreatedAt: doc.CreatedAt}
+	for i, chunk := range doc.Chunks {
+		if len(chunk.Embedding) == 0 {
+			continue
+		}
+		e := ivfEntry{ref: chunkRef{DocID: doc.ID, Chunk: i}, vec: chunk.Embedding}
+		list := int32(-1)
+		if idx.trained() {
+			list = int32(vector.Nearest(idx.centroids, e.vec))
+			idx.lists[list] = append(idx.lists[list], e)
+		} else {
+			idx.pending = append(idx.pending, e)
+		}
+		if !slices.Contains(entry.Lists, list) {
+			entry.Lists = append(entry.Lists, list)
+		}
+		idx.size++
+	}
+	idx.docs[doc.ID] = entry
+	idx.changed = true
+
+	if !idx.trained() && idx.size >= idx.params.MinChunks {
+		idx.train()
+	}
+}
+
+func (idx *ivfIndex) remove(id string) {
+	entry, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+
+	keep := func(entries []ivfEntry) []ivfEntry {
+		n := len(entries)
+		entries = slices.DeleteFunc(entries, func(e ivfEntry) bool { return e.ref.DocID == id })
+		idx.size -= n - len(entries)
+		return entries
+	}
+	for _, list := range entry.Lists {
+		if list < 0 {
+			idx.pending = keep(idx.pending)
+		} else {
+			idx.lists[list] = keep(idx.lists[list])
+		}
+	}
+	delete(idx.docs, id)
+	idx.changed = true
+}
+
+// train runs k-means over a sample of all the chunks and redistributes them into the new lists.
+func (idx *ivfIndex) train() {
+	all := idx.pending
+	for _, list := range idx.lists {
+		all = append(all, list...)
+	}
+	if len(all) == 0 {
+		return
+	}
+
+	nlist := idx.params.NList
+	if nlist <= 0 {
+		nlist = int(math.Sqrt(float64(len(all))))
+	}
+	nlist = max(1, min(nlist, len(all)))
+
+	sample := make([][]float32, 0, min(len(all), nlist*ivfTrainSamplesPerList))
+	for _, i := range idx.rng.Perm(len(all))[:cap(sample)] {
+		sample = append(sample, all[i].vec)
+	}
+	idx.centroids = vector.KMeans(sample, nlist, ivfTrainIterations, idx.rng)
+
+	idx.lists = make([][]ivfEntry, len(idx.centroids))
+	idx.pending = nil
+	for id, doc := range idx.docs {
+		doc.Lists = doc.Lists[:0]
+		idx.docs[id] = doc
+	}
+	for _, e := range all {
+		list := int32(vector.Nearest(idx.centroids, e.vec))
+		idx.lists[list] = append(idx.lists[list], e)
+
+		doc := idx.docs[e.ref.DocID]
+		if !slices.Contains(doc.Lists, list) {
+			doc.Lists = append(doc.Lists, list)
+			idx.docs[e.ref.DocID] = doc
+		}
+	}
+	idx.changed = true
+}
+
+func (idx *ivfIndex) search(ctx context.Context, query []float32, k int) ([]chunkHit, error) {
+	if !idx.trained() || k <= 0 {
+		return nil, nil
+	}
+
+	probes := make([]candidate, len(idx.centroids))
+	for i, c := range idx.centroids {
+		// candidates are ordered by descending score, so closer centroids get higher scores
+		probes[i] = candidate{id: int32(i), score: -vector.SquaredDistance(query, c)}
+	}
+	slices.SortFunc(probes, compareCandidates)
+
+	results := &candidateHeap{}
+	var entries []ivfEntry
+	for _, probe := range probes[:min(idx.params.NProbe, len(probes))] {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		for _, e := range idx.lists[probe.id] {
+			score := vector.FastCosineSimilarity(query, e.vec)
+			if results.len() < k || score > results.top().score {
+				results.push(candidate{id: int32(len(entries)), score: score})
+				entries = append(entries, e)
+				if results.len() > k {
+					results.pop()
+				}
+			}
+		}
+	}
+
+	found := results.items
+	slices.SortFunc(found, compareCandidates)
+	hits := make([]chunkHit, len(found))
+	for i, c := range found {
+		hits[i] = chunkHit{ref: entries[c.id].ref, score: c.score}
+	}
+	return hits, nil
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIVFRecall(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	docs := makeRandomDocuments(rng, 4000, 16)
+	queries := make([][]float32, 50)
+	for i := range queries {
+		queries[i] = randomUnitVector(rng, 16)
+	}
+
+	idx := newIVFIndex(IVFParams{NList: 32, NProbe: 8, MinChunks: 100})
+	idx.build(docs)
+	assert.True(t, idx.ready())
+	assert.Len(t, idx.centroids, 32)
+
+	recall := indexRecall(idx, docs, queries, 10)
+	assert.GreaterOrEqual(t, recall, 0.8)
+
+	idx.params.NProbe = 32
+	assert.Equal(t, 1.0, indexRecall(idx, docs, queries, 10))
+}
+
+func TestIVFTrainsOnceLargeEnough(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	docs := makeRandomDocuments(rng, 300, 8)
+
+	idx := newIVFIndex(IVFParams{NProbe: 4, MinChunks: 200})
+	for _, doc := range docs[:199] {
+		idx.add(doc)
+	}
+	assert.False(t, idx.ready())
+	assert.Len(t, idx.pending, 199)
+
+	for _, doc := range docs[199:] {
+		idx.add(doc)
+	}
+	assert.True(t, idx.ready())
+	assert.Empty(t, idx.pending)
+
+	idx.remove(docs[0].ID)
+	assert.Equal(t, 299, idx.size)
+	for _, hit := range searchHits(t, idx, docs[0].Chunks[0].Embedding, 20) {
+		assert.NotEqual(t, docs[0].ID, hit.ref.DocID)
+	}
+}
+
+func TestIVFStoreFallsBackToBruteForce(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "store.skdb")
+	ctx := context.Background()
+	rng := rand.New(rand.NewSource(3))
+	docs := makeRandomDocuments(rng, 50, 8)
+
+	ds, err := NewDiskStore(file, WithIndex(IVFIndex), WithIVF(IVFParams{NList: 4, MinChunks: 100}))
+	assert.NoError(t, err)
+	for _, doc := range docs {
+		assert.NoError(t, ds.Index(ctx, doc))
+	}
+	assert.False(t, ds.index.ready())
+
+	results, err := ds.Search(ctx, docs[5].Chunks[0].Embedding, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, docs[5].ID, results[0].Document.ID)
+
+	ds.index.(*ivfIndex).params.MinChunks = 10
+	assert.NoError(t, ds.RebuildIndex(ctx))
+	assert.True(t, ds.index.ready())
+	assert.NoError(t, ds.Close())
+
+	_, err = os.Stat(ivfPath(file))
+	assert.NoError(t, err)
+
+	// the store records its index, so it is loaded without asking for it
+	ds, err = NewDiskStore(file, WithIVF(IVFParams{NList: 4, NProbe: 4, MinChunks: 10}))
+	assert.NoError(t, err)
+	defer ds.Close()
+	assert.Equal(t, IVFIndex, ds.VectorIndex())
+	assert.True(t, ds.index.ready())
+
+	results, err = ds.Search(ctx, docs[5].Chunks[0].Embedding, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, docs[5].ID, results[0].Document.ID)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = ds.Search(cancelled, docs[5].Chunks[0].Embedding, 1)
+	assert.ErrorIs(t, err, context.Canceled)
+}
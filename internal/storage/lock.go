@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrLocked = errors.New("store is locked")
+
+type LockMode int
+
+const (
+	// SharedLock lets several readers use the store at once while keeping writers out.
+	SharedLock LockMode = iota
+	// ExclusiveLock keeps every other process out of the store.
+	ExclusiveLock
+)
+
+const lockRetryInterval = 50 * time.Millisecond
+
+// errWouldBlock is returned by lockFile when another process holds a conflicting lock.
+var errWouldBlock = errors.New("lock is held by another process")
+
+// Lock is an advisory lock on the file next to a store. The holder of an
+// exclusive lock writes its PID in the file so other processes can report it.
+type Lock struct {
+	file *os.File
+	mode LockMode
+}
+
+func LockPath(storePath string) string {
+	return storePath + ".lock"
+}
+
+// AcquireLock locks the store at storePath, waiting up to timeout for other processes to release it.
+func AcquireLock(storePath string, mode LockMode, timeout time.Duration) (*Lock, error) {
+	f, err := os.OpenFile(LockPath(storePath), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := lockFile(f, mode)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errWouldBlock) {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock store: %w", err)
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, lockHolderError(storePath)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+
+	// failing to record the PID only makes the error shown to others less helpful
+	switch mode {
+	case ExclusiveLock:
+		if err := f.Truncate(0); err == nil {
+			f.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+		}
+	case SharedLock:
+		// no writer can hold the store, so a PID left in the file is that of one that crashed
+		f.Truncate(0)
+	}
+
+	return &Lock{file: f, mode: mode}, nil
+}
+
+// lockHolderError reports who holds the store at storePath: the writer whose PID is in the lock
+// file, unless that process is gone and the lock is held by readers, which record no PID.
+func lockHolderError(storePath string) error {
+	data, _ := os.ReadFile(LockPath(storePath))
+	if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && pid > 0 && processAlive(pid) {
+		return fmt.Errorf("%w by PID %d", ErrLocked, pid)
+	}
+	return fmt.Errorf("%w by another process", ErrLocked)
+}
+
+// Release unlocks the store. It is safe to call on a nil Lock.
+func (l *Lock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+
+	if l.mode == ExclusiveLock {
+		l.file.Truncate(0)
+	}
+	err := unlockFile(l.file)
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	l.file = nil
+	return err
+}
@@ -0,0 +1,19 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package storage
+
+import "os"
+
+// Platforms without flock or LockFileEx run without cross-process locking.
+
+func lockFile(_ *os.File, _ LockMode) error {
+	return nil
+}
+
+func unlockFile(_ *os.File) error {
+	return nil
+}
+
+func processAlive(_ int) bool {
+	return false
+}
@@ -0,0 +1,94 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExclusiveLockReportsHolder(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "store.skdb")
+
+	writer, err := NewDiskStore(file, WithLock(ExclusiveLock, 0))
+	assert.NoError(t, err)
+
+	_, err = NewDiskStore(file, WithLock(ExclusiveLock, 100*time.Millisecond))
+	assert.ErrorIs(t, err, ErrLocked)
+	assert.ErrorContains(t, err, fmt.Sprintf("PID %d", os.Getpid()))
+
+	_, err = NewDiskStore(file, WithLock(SharedLock, 0))
+	assert.ErrorIs(t, err, ErrLocked)
+
+	assert.NoError(t, writer.Close())
+
+	reader, err := NewDiskStore(file, WithLock(SharedLock, 0))
+	assert.NoError(t, err)
+	defer reader.Close()
+}
+
+func TestSharedLockAllowsReaders(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "store.skdb")
+
+	first, err := NewDiskStore(file, WithLock(SharedLock, 0))
+	assert.NoError(t, err)
+	defer first.Close()
+
+	second, err := NewDiskStore(file, WithLock(SharedLock, 0))
+	assert.NoError(t, err)
+	defer second.Close()
+
+	_, err = NewDiskStore(file, WithLock(ExclusiveLock, 0))
+	assert.ErrorIs(t, err, ErrLocked)
+
+	assert.ErrorIs(t, first.Index(context.Background(), makeValidDocument(t, "a")), ErrReadOnly)
+}
+
+func TestLockWaitsForRelease(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "store.skdb")
+
+	writer, err := NewDiskStore(file, WithLock(ExclusiveLock, 0))
+	assert.NoError(t, err)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		writer.Close()
+	}()
+
+	ds, err := NewDiskStore(file, WithLock(ExclusiveLock, 5*time.Second))
+	assert.NoError(t, err)
+	assert.NoError(t, ds.Close())
+}
+
+// deadPID returns the PID of a process that has exited.
+func deadPID(t *testing.T) int {
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	assert.NoError(t, cmd.Run())
+	return cmd.Process.Pid
+}
+
+func TestLockIgnoresPIDOfCrashedWriter(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "store.skdb")
+	stale := []byte(fmt.Sprint(deadPID(t)))
+	assert.NoError(t, os.WriteFile(LockPath(file), stale, 0o644))
+
+	// readers clear the PID, since no writer can hold the lock alongside them
+	reader, err := NewDiskStore(file, WithLock(SharedLock, 0))
+	assert.NoError(t, err)
+	defer reader.Close()
+	data, err := os.ReadFile(LockPath(file))
+	assert.NoError(t, err)
+	assert.Empty(t, data)
+
+	// a PID whose process is gone is not reported
+	assert.NoError(t, os.WriteFile(LockPath(file), stale, 0o644))
+	_, err = NewDiskStore(file, WithLock(ExclusiveLock, 0))
+	assert.ErrorIs(t, err, ErrLocked)
+	assert.ErrorContains(t, err, "by another process")
+}
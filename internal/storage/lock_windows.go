@@ -0,0 +1,66 @@
+//go:build windows
+
+package storage
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+
+	errorLockViolation syscall.Errno = 33
+
+	processQueryLimitedInformation = 0x1000
+	stillActive                    = 259
+
+	// the locked byte lies past the PID written in the file, so other processes can still read it
+	lockOffset = 1 << 30
+)
+
+func lockFile(f *os.File, mode LockMode) error {
+	flags := uint32(lockfileFailImmediately)
+	if mode == ExclusiveLock {
+		flags |= lockfileExclusiveLock
+	}
+
+	ol := &syscall.Overlapped{Offset: lockOffset}
+	r1, _, err := procLockFileEx.Call(f.Fd(), uintptr(flags), 0, 1, 0, uintptr(unsafe.Pointer(ol)))
+	if r1 == 0 {
+		if err == errorLockViolation {
+			return errWouldBlock
+		}
+		return err
+	}
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	ol := &syscall.Overlapped{Offset: lockOffset}
+	r1, _, err := procUnlockFileEx.Call(f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(ol)))
+	if r1 == 0 {
+		return err
+	}
+	return nil
+}
+
+// processAlive reports whether the process with the given PID is running.
+func processAlive(pid int) bool {
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(h)
+
+	var code uint32
+	return syscall.GetExitCodeProcess(h, &code) == nil && code == stillActive
+}
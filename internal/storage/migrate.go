@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/jnaraujo/seekr/internal/config"
+	"github.com/jnaraujo/seekr/internal/document"
+)
+
+// migration rewrites a store file of one format version into the next version.
+type migration func(r *bufio.Reader, w *bufio.Writer) error
+
+// migrations maps every older format version to the step upgrading it to the version after it.
+var migrations = map[uint16]migration{
+	// version 0 stored all documents as a single gob-encoded slice
+	0: migrateGobSnapshot,
+	// version 1 was the record log with a header holding only the magic and the version
+	1: migrateEmbeddingHeader,
+	// version 2 had no quantization in the header
+	2: migrateQuantizationHeader,
+	// version 3 had no index in the header
+	3: migrateIndexHeader,
+}
+
+// migrate upgrades data from format version from to currentVersion, one step at a time.
+func migrate(data []byte, from uint16) ([]byte, error) {
+	for version := from; version < currentVersion; version++ {
+		step, ok := migrations[version]
+		if !ok {
+			return nil, fmt.Errorf("no migration from store format version %d", version)
+		}
+
+		var buf bytes.Buffer
+		w := bufio.NewWriter(&buf)
+		err := step(bufio.NewReader(bytes.NewReader(data)), w)
+		if err == nil {
+			err = w.Flush()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to migrate store from version %d to %d: %w", version, version+1, err)
+		}
+		data = buf.Bytes()
+	}
+	return data, nil
+}
+
+func migrateGobSnapshot(r *bufio.Reader, w *bufio.Writer) error {
+	var docs []document.Document
+	if err := gob.NewDecoder(r).Decode(&docs); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to decode gob data: %w", err)
+	}
+
+	var header [6]byte
+	copy(header[:], fileMagic[:])
+	binary.LittleEndian.PutUint16(header[len(fileMagic):], 1)
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+
+	for _, doc := range docs {
+		rec, err := encodeAddRecord(doc)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateEmbeddingHeader(r *bufio.Reader, w *bufio.Writer) error {
+	if _, err := r.Discard(len(fileMagic) + 2); err != nil {
+		return err
+	}
+
+	// stores before version 2 could only be created with the default model
+	header := fileHeader{Model: config.DefaultEmbeddingModel, Dimension: config.EmbeddingDimension}
+	if err := writeV2Header(w, header); err != nil {
+		return err
+	}
+
+	_, err := io.Copy(w, r)
+	return err
+}
+
+const (
+	// v2HeaderSize is the size of the version 2 header, which lacked the quantization byte.
+	v2HeaderSize = v3HeaderSize - 1
+	// v3HeaderSize is the size of the version 3 header, which lacked the index byte.
+	v3HeaderSize = fileHeaderSize - 1
+)
+
+// writeV2Header writes h in the version 2 layout, so that the next migration step can read it.
+func writeV2Header(w io.Writer, h fileHeader) error {
+	// the version 2 layout ends right before the quantization byte
+	return writeOldHeader(w, h, 2, v2HeaderSize)
+}
+
+// writeV3Header writes h in the version 3 layout, so that the next migration step can read it.
+func writeV3Header(w io.Writer, h fileHeader) error {
+	// the version 3 layout ends right before the index byte
+	return writeOldHeader(w, h, 3, v3HeaderSize)
+}
+
+// writeOldHeader writes the first size bytes of the current layout of h as the given version,
+// ending them with their own checksum.
+func writeOldHeader(w io.Writer, h fileHeader, version uint16, size int) error {
+	var buf bytes.Buffer
+	if err := writeFileHeader(&buf, h); err != nil {
+		return err
+	}
+
+	header := buf.Bytes()[:size-4]
+	binary.LittleEndian.PutUint16(header[len(fileMagic):], version)
+	header = binary.LittleEndian.AppendUint32(header, crc32.ChecksumIEEE(header))
+	_, err := w.Write(header)
+	return err
+}
+
+func migrateQuantizationHeader(r *bufio.Reader, w *bufio.Writer) error {
+	old := make([]byte, v2HeaderSize)
+	if _, err := io.ReadFull(r, old); err != nil {
+		return fmt.Errorf("failed to read file header: %w", err)
+	}
+	crcOffset := v2HeaderSize - 4
+	if crc32.ChecksumIEEE(old[:crcOffset]) != binary.LittleEndian.Uint32(old[crcOffset:]) {
+		return errors.New("corrupt file header: checksum mismatch")
+	}
+
+	offset := len(fileMagic) + 2
+	dimension := binary.LittleEndian.Uint32(old[offset:])
+	offset += 4
+	modelLen := int(binary.LittleEndian.Uint16(old[offset:]))
+	offset += 2
+	if modelLen > maxModelNameLen {
+		return errors.New("corrupt file header: model name is too long")
+	}
+
+	header := fileHeader{
+		Model:        string(old[offset : offset+modelLen]),
+		Dimension:    int(dimension),
+		Quantization: NoQuantization,
+	}
+	if err := writeV3Header(w, header); err != nil {
+		return err
+	}
+
+	_, err := io.Copy(w, r)
+	return err
+}
+
+func migrateIndexHeader(r *bufio.Reader, w *bufio.Writer) error {
+	old := make([]byte, v3HeaderSize)
+	if _, err := io.ReadFull(r, old); err != nil {
+		return fmt.Errorf("failed to read file header: %w", err)
+	}
+	crcOffset := v3HeaderSize - 4
+	if crc32.ChecksumIEEE(old[:crcOffset]) != binary.LittleEndian.Uint32(old[crcOffset:]) {
+		return errors.New("corrupt file header: checksum mismatch")
+	}
+
+	// the layouts only differ by the index byte before the checksum; stores before version 4
+	// were searched with the index passed on every run, so they start without one
+	header := append(old[:crcOffset:crcOffset], BruteForceIndex.code())
+	binary.LittleEndian.PutUint16(header[len(fileMagic):], 4)
+	header = binary.LittleEndian.AppendUint32(header, crc32.ChecksumIEEE(header))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	_, err := io.Copy(w, r)
+	return err
+}
@@ -0,0 +1,92 @@
+package storage
+
+import "time"
+
+type Option func(*DiskStore)
+
+// WithCompactionThreshold sets when the store compacts itself in the background: once at least
+// minBytes of the file are reclaimable and they make up at least ratio of its size.
+// A minBytes of 0 disables automatic compaction.
+func WithCompactionThreshold(minBytes int64, ratio float64) Option {
+	return func(ds *DiskStore) {
+		ds.compactMinBytes = minBytes
+		ds.compactRatio = ratio
+	}
+}
+
+// WithSkipCorrupt makes the store skip records that fail verification when loading instead of
+// refusing to open. The skipped records are available through Problems.
+func WithSkipCorrupt() Option {
+	return func(ds *DiskStore) {
+		ds.skipCorrupt = true
+	}
+}
+
+// WithLock holds a lock on the store from the moment it is opened until it is closed, waiting
+// up to timeout for other processes. Stores opened with a SharedLock are read-only.
+func WithLock(mode LockMode, timeout time.Duration) Option {
+	return func(ds *DiskStore) {
+		ds.locking = true
+		ds.lockMode = mode
+		ds.lockTimeout = timeout
+		ds.readOnly = mode == SharedLock
+	}
+}
+
+// WithIndex answers searches with the given vector index, saved next to the store, and records
+// it in the store, so that later opens without this option keep using and updating it. A store
+// without an index takes the one requested, while one recorded with another index refuses to
+// open unless WithIndexReplacement is passed too.
+func WithIndex(kind IndexKind) Option {
+	return func(ds *DiskStore) {
+		ds.indexKind = kind
+	}
+}
+
+// WithIndexReplacement lets WithIndex replace the vector index recorded in the store.
+func WithIndexReplacement() Option {
+	return func(ds *DiskStore) {
+		ds.replaceIndex = true
+	}
+}
+
+// WithHNSW configures the HNSW graph index of stores using HNSWIndex, which answers searches
+// instead of comparing the query against every chunk.
+func WithHNSW(params HNSWParams) Option {
+	return func(ds *DiskStore) {
+		ds.hnswParams = params
+	}
+}
+
+// WithIVF configures the inverted file index of stores using IVFIndex. Until the store holds
+// IVFParams.MinChunks chunks, searches still compare the query against every chunk.
+func WithIVF(params IVFParams) Option {
+	return func(ds *DiskStore) {
+		ds.ivfParams = params
+	}
+}
+
+// WithQuantization holds the embeddings of the store in memory as q and records q in the store,
+// so that later opens without this option keep using it.
+func WithQuantization(q Quantization) Option {
+	return func(ds *DiskStore) {
+		ds.quantization = q
+	}
+}
+
+// WithPQ configures the product quantization of stores quantized with ProductQuantization.
+func WithPQ(params PQParams) Option {
+	return func(ds *DiskStore) {
+		ds.pqParams = params
+	}
+}
+
+// WithEmbedding opens the store for embeddings of the given model and dimension, refusing stores
+// that hold documents embedded differently. A new store records them, and a dimension of 0 is
+// taken from the first document indexed.
+func WithEmbedding(model string, dimension int) Option {
+	return func(ds *DiskStore) {
+		ds.model = model
+		ds.dimension = dimension
+	}
+}
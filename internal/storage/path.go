@@ -6,7 +6,6 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-	"unicode/utf8"
 
 	"github.com/jnaraujo/seekr/internal/config"
 )
@@ -71,10 +70,6 @@ func FilePathWalkDir(root string) ([]string, error) {
 	return files, err
 }
 
-func IsFileValid(content []byte) bool {
-	return utf8.Valid(content)
-}
-
 func IsHidden(path string) bool {
 	base := filepath.Base(path)
 
@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+
+	"github.com/jnaraujo/seekr/internal/document"
+	"github.com/jnaraujo/seekr/internal/vector"
+)
+
+// PQParams configures product quantization.
+type PQParams struct {
+	// Subspaces is the number of bytes every chunk embedding is compressed to. 0 uses one byte per 8 dimensions.
+	Subspaces int
+	// MinChunks is the number of chunks needed to train the codebooks. Until the store holds that
+	// many, chunks keep their full embeddings in memory.
+	MinChunks int
+	// Rerank is how many documents per requested result are rescored with their full embeddings
+	// read from disk. 0 ranks documents by their codes alone.
+	Rerank int
+}
+
+func DefaultPQParams() PQParams {
+	return PQParams{Subspaces: 0, MinChunks: 1024, Rerank: 8}
+}
+
+const (
+	pqFileVersion = 1
+	// the codebooks are trained on a sample of at most this many chunks
+	pqTrainSamples    = 8192
+	pqTrainIterations = 10
+)
+
+// pqPath returns the file where the codebooks of the store at storePath are saved.
+func pqPath(storePath string) string {
+	return storePath + ".pq"
+}
+
+type pqFile struct {
+	Version   int
+	Quantizer *vector.ProductQuantizer
+}
+
+// subspaces returns the number of subspaces to split embeddings of dimension dim into.
+func (p PQParams) subspaces(dim int) int {
+	if p.Subspaces > 0 {
+		return min(p.Subspaces, dim)
+	}
+	return max(1, dim/8)
+}
+
+// loadCodebooks reads the codebooks saved next to the store, unless they do not fit its embeddings.
+func (ds *DiskStore) loadCodebooks() {
+	f, err := os.Open(pqPath(ds.filePath))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	var file pqFile
+	if err := gob.NewDecoder(bufio.NewReader(f)).Decode(&file); err != nil {
+		return
+	}
+	pq := file.Quantizer
+	// codebooks trained for embeddings of another dimension are retrained like missing ones
+	if file.Version != pqFileVersion || pq == nil || pq.Dim != ds.header.Dimension ||
+		pq.Subspaces() != ds.pqParams.subspaces(pq.Dim) {
+		return
+	}
+	ds.pq = pq
+}
+
+func (ds *DiskStore) saveCodebooks() error {
+	err := writeFileAtomic(pqPath(ds.filePath), func(w *bufio.Writer) error {
+		return gob.NewEncoder(w).Encode(pqFile{Version: pqFileVersion, Quantizer: ds.pq})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save pq codebooks: %w", err)
+	}
+	return nil
+}
+
+// maybeTrainCodebooks trains the codebooks of a product quantized store once it holds enough chunks.
+func (ds *DiskStore) maybeTrainCodebooks() {
+	if ds.header.Quantization != ProductQuantization || ds.pq != nil || ds.pqPending < ds.pqParams.MinChunks {
+		return
+	}
+	if err := ds.trainCodebooks(); err != nil {
+		slog.Warn("failed to train pq codebooks", "error", err)
+	}
+}
+
+// trainCodebooks trains the codebooks on a sample of the chunks and encodes every document
+// with them. Chunks already encoded are read back from disk.
+func (ds *DiskStore) trainCodebooks() error {
+	rng := rand.New(rand.NewSource(1))
+
+	var sample [][]float32
+	for _, i := range rng.Perm(len(ds.documents)) {
+		if len(sample) >= pqTrainSamples {
+			break
+		}
+		doc, err := ds.fullDocument(ds.documents[i])
+		if err != nil {
+			return err
+		}
+		for _, chunk := range doc.Chunks {
+			if len(chunk.Embedding) > 0 && (len(sample) == 0 || len(chunk.Embedding) == len(sample[0])) {
+				sample = append(sample, chunk.Embedding)
+			}
+		}
+	}
+	if len(sample) == 0 {
+		return nil
+	}
+
+	pq, err := vector.TrainProductQuantizer(sample, ds.pqParams.subspaces(len(sample[0])), pqTrainIterations, rng)
+	if err != nil {
+		return err
+	}
+
+	// encode everything before switching, so that a failed read leaves the store as it was
+	codes := make([][][]byte, len(ds.documents))
+	for i, doc := range ds.documents {
+		full, err := ds.fullDocument(doc)
+		if err != nil {
+			return err
+		}
+		for _, chunk := range full.Chunks {
+			code, err := pq.Encode(chunk.Embedding)
+			if err != nil {
+				return fmt.Errorf("failed to encode document %s: %w", doc.ID, err)
+			}
+			codes[i] = append(codes[i], code)
+		}
+	}
+
+	ds.pq = pq
+	ds.pqPending = 0
+	for i, doc := range ds.documents {
+		ds.quantized[doc.ID] = quantizedChunks{pq: codes[i]}
+		ds.documents[i].Chunks = withoutEmbeddings(doc.Chunks)
+	}
+
+	if ds.readOnly {
+		return nil
+	}
+	return ds.saveCodebooks()
+}
+
+// fullDocument returns doc with its full embeddings, reading them back from disk if the store
+// only holds quantized ones.
+func (ds *DiskStore) fullDocument(doc document.Document) (document.Document, error) {
+	if _, ok := ds.quantized[doc.ID]; !ok {
+		return doc, nil
+	}
+	return ds.readDocument(doc.ID)
+}
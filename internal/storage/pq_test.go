@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPQRecall(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	docs, queries := makeClusteredDocuments(rng, 2000, 50, 128)
+
+	for _, rerank := range []int{0, DefaultPQParams().Rerank} {
+		params := DefaultPQParams()
+		params.Rerank = rerank
+		ds, err := NewDiskStore(filepath.Join(t.TempDir(), "store.skdb"), WithQuantization(ProductQuantization), WithPQ(params))
+		assert.NoError(t, err)
+		for _, doc := range docs {
+			assert.NoError(t, ds.Index(context.Background(), doc))
+		}
+		assert.NotNil(t, ds.pq)
+
+		// codes alone only roughly order the documents, reranking with the full embeddings restores the order
+		recall := storeRecall(t, ds, docs, queries, 10)
+		t.Logf("pq rerank %d recall@10: %.3f", rerank, recall)
+		if rerank == 0 {
+			assert.GreaterOrEqual(t, recall, 0.35)
+		} else {
+			assert.GreaterOrEqual(t, recall, 0.95)
+		}
+		assert.NoError(t, ds.Close())
+	}
+}
+
+func TestPQTrainsOnceLargeEnough(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "store.skdb")
+	ctx := context.Background()
+	rng := rand.New(rand.NewSource(1))
+	docs := makeRandomDocuments(rng, 100, 32)
+
+	params := PQParams{Subspaces: 4, MinChunks: 50, Rerank: 2}
+	ds, err := NewDiskStore(file, WithQuantization(ProductQuantization), WithPQ(params))
+	assert.NoError(t, err)
+
+	for _, doc := range docs[:49] {
+		assert.NoError(t, ds.Index(ctx, doc))
+	}
+	assert.Nil(t, ds.pq)
+	held, _ := ds.Get(ctx, docs[0].ID)
+	assert.NotNil(t, held.Chunks[0].Embedding)
+
+	for _, doc := range docs[49:] {
+		assert.NoError(t, ds.Index(ctx, doc))
+	}
+	assert.NotNil(t, ds.pq)
+	held, _ = ds.Get(ctx, docs[0].ID)
+	assert.Nil(t, held.Chunks[0].Embedding)
+	assert.Len(t, ds.quantized[docs[0].ID].pq[0], 4)
+
+	results, err := ds.Search(ctx, docs[3].Chunks[0].Embedding, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, docs[3].ID, results[0].Document.ID)
+	assert.NoError(t, ds.Close())
+
+	_, err = os.Stat(pqPath(file))
+	assert.NoError(t, err)
+
+	// the saved codebooks are used again, and retraining replaces them
+	ds, err = NewDiskStore(file, WithPQ(params))
+	assert.NoError(t, err)
+	defer ds.Close()
+	assert.Equal(t, ProductQuantization, ds.Quantization())
+	assert.NotNil(t, ds.pq)
+
+	before := ds.pq
+	assert.NoError(t, ds.RebuildIndex(ctx))
+	assert.NotSame(t, before, ds.pq)
+
+	results, err = ds.Search(ctx, docs[3].Chunks[0].Embedding, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, docs[3].ID, results[0].Document.ID)
+}
+
+func TestPQRetrainsCodebooksOfAnotherDimension(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	rng := rand.New(rand.NewSource(2))
+	params := PQParams{Subspaces: 4, MinChunks: 50, Rerank: 2}
+
+	wide, err := NewDiskStore(filepath.Join(dir, "wide.skdb"), WithQuantization(ProductQuantization), WithPQ(params))
+	assert.NoError(t, err)
+	for _, doc := range makeRandomDocuments(rng, 50, 32) {
+		assert.NoError(t, wide.Index(ctx, doc))
+	}
+	assert.NoError(t, wide.Close())
+
+	file := filepath.Join(dir, "narrow.skdb")
+	docs := makeRandomDocuments(rng, 50, 16)
+	ds, err := NewDiskStore(file, WithQuantization(ProductQuantization), WithPQ(params))
+	assert.NoError(t, err)
+	for _, doc := range docs {
+		assert.NoError(t, ds.Index(ctx, doc))
+	}
+	assert.NoError(t, ds.Close())
+
+	// codebooks left over from a store of another dimension are retrained instead of used
+	codebooks, err := os.ReadFile(pqPath(filepath.Join(dir, "wide.skdb")))
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(pqPath(file), codebooks, 0o644))
+
+	ds, err = NewDiskStore(file, WithPQ(params))
+	assert.NoError(t, err)
+	defer ds.Close()
+	assert.Equal(t, 16, ds.pq.Dim)
+
+	results, err := ds.Search(ctx, docs[3].Chunks[0].Embedding, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, docs[3].ID, results[0].Document.ID)
+}
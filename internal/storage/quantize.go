@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/jnaraujo/seekr/internal/document"
+	"github.com/jnaraujo/seekr/internal/embeddings"
+	"github.com/jnaraujo/seekr/internal/vector"
+)
+
+// Quantization selects how a store holds the embeddings of its documents in memory.
+// The records on disk always keep the full embeddings, so a store can switch between them.
+type Quantization string
+
+const (
+	// NoQuantization keeps every embedding as float32.
+	NoQuantization Quantization = "none"
+	// ScalarQuantization keeps every embedding as int8, a quarter of the memory.
+	ScalarQuantization Quantization = "int8"
+	// BinaryQuantization keeps one bit per component, a 32nd of the memory. Searches pick
+	// candidates by Hamming distance and rescore them with the embeddings read from disk.
+	BinaryQuantization Quantization = "binary"
+	// ProductQuantization keeps a byte per group of components once the store holds enough
+	// chunks to train its codebooks. See PQParams.
+	ProductQuantization Quantization = "pq"
+)
+
+func ParseQuantization(s string) (Quantization, error) {
+	switch q := Quantization(s); q {
+	case NoQuantization, ScalarQuantization, BinaryQuantization, ProductQuantization:
+		return q, nil
+	}
+	return "", fmt.Errorf("unknown quantization %q", s)
+}
+
+// code returns the byte recording q in the file header.
+func (q Quantization) code() byte {
+	switch q {
+	case ScalarQuantization:
+		return 1
+	case BinaryQuantization:
+		return 2
+	case ProductQuantization:
+		return 3
+	}
+	return 0
+}
+
+func quantizationFromCode(code byte) (Quantization, bool) {
+	switch code {
+	case 0:
+		return NoQuantization, true
+	case 1:
+		return ScalarQuantization, true
+	case 2:
+		return BinaryQuantization, true
+	case 3:
+		return ProductQuantization, true
+	}
+	return "", false
+}
+
+// binaryRescoreFactor is how many documents per requested result a binary search
+// rescores with their full embeddings.
+const binaryRescoreFactor = 8
+
+// quantizedChunks holds the quantized embeddings of the chunks of a document.
+type quantizedChunks struct {
+	int8s  []vector.Int8Vector
+	binary []vector.BinaryVector
+	pq     [][]byte
+}
+
+// hold returns doc as the store keeps it in memory. With quantization, the chunk embeddings
+// are replaced by their quantized form in ds.quantized.
+func (ds *DiskStore) hold(doc document.Document) (document.Document, error) {
+	switch ds.header.Quantization {
+	case NoQuantization:
+		return doc, nil
+	case ProductQuantization:
+		if ds.pq == nil {
+			// chunks keep their full embeddings until there are enough of them to train the codebooks
+			ds.pqPending += len(doc.Chunks)
+			return doc, nil
+		}
+	}
+
+	var q quantizedChunks
+	for i, chunk := range doc.Chunks {
+		switch ds.header.Quantization {
+		case ScalarQuantization:
+			q.int8s = append(q.int8s, vector.QuantizeInt8(chunk.Embedding))
+		case BinaryQuantization:
+			q.binary = append(q.binary, vector.QuantizeBinary(chunk.Embedding))
+		case ProductQuantization:
+			code, err := ds.pq.Encode(chunk.Embedding)
+			if err != nil {
+				return document.Document{}, fmt.Errorf("failed to quantize chunk %d: %w", i, err)
+			}
+			q.pq = append(q.pq, code)
+		}
+	}
+	ds.quantized[doc.ID] = q
+	doc.Chunks = withoutEmbeddings(doc.Chunks)
+	return doc, nil
+}
+
+// withoutEmbeddings returns a copy of chunks with their embeddings dropped.
+func withoutEmbeddings(chunks []embeddings.Chunk) []embeddings.Chunk {
+	chunks = slices.Clone(chunks)
+	for i := range chunks {
+		chunks[i].Embedding = nil
+	}
+	return chunks
+}
+
+// release forgets the quantized embeddings of a document leaving the store.
+func (ds *DiskStore) release(doc document.Document) {
+	if _, ok := ds.quantized[doc.ID]; !ok && ds.header.Quantization == ProductQuantization {
+		ds.pqPending -= len(doc.Chunks)
+	}
+	delete(ds.quantized, doc.ID)
+}
+
+// scorer returns a function giving the similarity between query and the closest chunk of a
+// document, and that chunk, computed from the embeddings the store holds in memory. Binary
+// quantized stores score by negated Hamming distance, which only ranks documents.
+func (ds *DiskStore) scorer(query []float32) func(doc document.Document) (float32, int) {
+	switch ds.header.Quantization {
+	case ScalarQuantization:
+		return func(doc document.Document) (float32, int) {
+			var bestScore float32
+			var bestChunk int
+			for i, code := range ds.quantized[doc.ID].int8s {
+				if score := code.Dot(query); score > bestScore {
+					bestScore, bestChunk = score, i
+				}
+			}
+			return bestScore, bestChunk
+		}
+	case BinaryQuantization:
+		bits := vector.QuantizeBinary(query)
+		return func(doc document.Document) (float32, int) {
+			bestScore := float32(math.Inf(-1))
+			var bestChunk int
+			for i, code := range ds.quantized[doc.ID].binary {
+				if score := -float32(vector.HammingDistance(bits, code)); score > bestScore {
+					bestScore, bestChunk = score, i
+				}
+			}
+			return bestScore, bestChunk
+		}
+	case ProductQuantization:
+		if ds.pq == nil {
+			break
+		}
+		table := ds.pq.Table(query)
+		return func(doc document.Document) (float32, int) {
+			var bestScore float32
+			var bestChunk int
+			for i, code := range ds.quantized[doc.ID].pq {
+				if score := table.Score(code); score > bestScore {
+					bestScore, bestChunk = score, i
+				}
+			}
+			return bestScore, bestChunk
+		}
+	}
+	return func(doc document.Document) (float32, int) {
+		return closestChunk(doc, query)
+	}
+}
+
+// closestChunk compares query with the full embedding of every chunk of doc.
+func closestChunk(doc document.Document, query []float32) (float32, int) {
+	var bestScore float32
+	var bestChunk int
+	for i, chunk := range doc.Chunks {
+		if score := vector.FastCosineSimilarity(query, chunk.Embedding); score > bestScore {
+			bestScore, bestChunk = score, i
+		}
+	}
+	return bestScore, bestChunk
+}
+
+// rescoreLimit returns how many documents a search for topK results shortlists by the embeddings
+// held in memory before rescoring them with their full embeddings, or 0 to rank without rescoring.
+func (ds *DiskStore) rescoreLimit(topK int) int {
+	switch ds.header.Quantization {
+	case BinaryQuantization:
+		return topK * binaryRescoreFactor
+	case ProductQuantization:
+		return topK * max(ds.pqParams.Rerank, 0)
+	}
+	return 0
+}
+
+// rescore reads the full embeddings of the candidate documents from disk and returns the topK
+// of them most similar to query.
+func (ds *DiskStore) rescore(candidates []candidate, query []float32, topK int) ([]SearchResult, error) {
+	results := make([]SearchResult, 0, len(candidates))
+	for _, c := range candidates {
+		doc := ds.documents[c.id]
+		full, err := ds.readDocument(doc.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to rescore document %q: %w", doc.ID, err)
+		}
+		score, chunk := closestChunk(full, query)
+		if score <= 0 {
+			continue
+		}
+		results = append(results, SearchResult{Document: doc, Score: score, BestMatchingChunk: chunk})
+	}
+
+	sortResults(results)
+	return results[:min(topK, len(results))], nil
+}
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jnaraujo/seekr/internal/document"
+	"github.com/jnaraujo/seekr/internal/embeddings"
+	"github.com/jnaraujo/seekr/internal/vector"
+	"github.com/stretchr/testify/assert"
+)
+
+// storeRecall returns the average fraction of the true top k, by vector.FastCosineSimilarity,
+// that the store search returns.
+func storeRecall(t *testing.T, ds *DiskStore, docs []document.Document, queries [][]float32, k int) float64 {
+	var found int
+	for _, q := range queries {
+		results, err := ds.Search(context.Background(), q, k)
+		assert.NoError(t, err)
+
+		got := make(map[string]bool)
+		for _, res := range results {
+			got[res.Document.ID] = true
+		}
+		for _, id := range bruteForceTopK(docs, q, k) {
+			if got[id] {
+				found++
+			}
+		}
+	}
+	return float64(found) / float64(len(queries)*k)
+}
+
+// makeClusteredDocuments returns n single-chunk documents whose embeddings are spread around
+// a few random centers, the way embeddings of related texts are, and queries drawn the same way.
+// On uniformly random vectors every document is about as far from the query as the others,
+// which leaves binary quantization nothing to tell them apart with.
+func makeClusteredDocuments(rng *rand.Rand, n, queries, dim int) ([]document.Document, [][]float32) {
+	centers := make([][]float32, 20)
+	for i := range centers {
+		centers[i] = randomUnitVector(rng, dim)
+	}
+	around := func() []float32 {
+		center := centers[rng.Intn(len(centers))]
+		noise := randomUnitVector(rng, dim)
+		v := make([]float32, dim)
+		for i := range v {
+			v[i] = center[i] + noise[i]
+		}
+		return vector.Normalize(v)
+	}
+
+	docs := make([]document.Document, n)
+	for i := range docs {
+		docs[i], _ = document.NewDocument(fmt.Sprintf("doc-%d", i), []embeddings.Chunk{{
+			Embedding: around(),
+		}}, time.Now(), fmt.Sprintf("path/%d", i))
+	}
+	qs := make([][]float32, queries)
+	for i := range qs {
+		qs[i] = around()
+	}
+	return docs, qs
+}
+
+func TestQuantizedRecall(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	docs, queries := makeClusteredDocuments(rng, 2000, 50, 256)
+
+	recalls := make(map[Quantization]float64)
+	for _, q := range []Quantization{NoQuantization, ScalarQuantization, BinaryQuantization} {
+		ds, err := NewDiskStore(filepath.Join(t.TempDir(), "store.skdb"), WithQuantization(q))
+		assert.NoError(t, err)
+		for _, doc := range docs {
+			assert.NoError(t, ds.Index(context.Background(), doc))
+		}
+
+		recalls[q] = storeRecall(t, ds, docs, queries, 10)
+		t.Logf("%s recall@10: %.3f", q, recalls[q])
+		assert.NoError(t, ds.Close())
+	}
+
+	assert.Equal(t, 1.0, recalls[NoQuantization])
+	assert.GreaterOrEqual(t, recalls[ScalarQuantization], 0.95)
+	assert.GreaterOrEqual(t, recalls[BinaryQuantization], 0.9)
+}
+
+func TestQuantizedStoreKeepsFullEmbeddingsOnDisk(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "store.skdb")
+	ctx := context.Background()
+	rng := rand.New(rand.NewSource(1))
+	docs := makeRandomDocuments(rng, 20, 16)
+
+	ds, err := NewDiskStore(file, WithQuantization(ScalarQuantization))
+	assert.NoError(t, err)
+	for _, doc := range docs {
+		assert.NoError(t, ds.Index(ctx, doc))
+	}
+	assert.NoError(t, ds.Remove(ctx, docs[0].ID))
+
+	held, err := ds.Get(ctx, docs[1].ID)
+	assert.NoError(t, err)
+	assert.Nil(t, held.Chunks[0].Embedding)
+
+	_, err = ds.Compact(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, ds.Close())
+
+	// the quantization is recorded in the store
+	ds, err = NewDiskStore(file)
+	assert.NoError(t, err)
+	assert.Equal(t, ScalarQuantization, ds.Quantization())
+	assert.NoError(t, ds.Close())
+
+	ds, err = NewDiskStore(file, WithQuantization(NoQuantization))
+	assert.NoError(t, err)
+	defer ds.Close()
+
+	full, err := ds.Get(ctx, docs[1].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, docs[1].Chunks[0].Embedding, full.Chunks[0].Embedding)
+}
+
+func TestBinaryQuantizedReadOnlyStore(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "store.skdb")
+	ctx := context.Background()
+	rng := rand.New(rand.NewSource(1))
+	docs := makeRandomDocuments(rng, 50, 64)
+
+	ds, err := NewDiskStore(file, WithQuantization(BinaryQuantization))
+	assert.NoError(t, err)
+	for _, doc := range docs {
+		assert.NoError(t, ds.Index(ctx, doc))
+	}
+	assert.NoError(t, ds.Close())
+
+	ds, err = NewDiskStore(file, WithLock(SharedLock, 0))
+	assert.NoError(t, err)
+	defer ds.Close()
+
+	results, err := ds.Search(ctx, docs[7].Chunks[0].Embedding, 1)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, docs[7].ID, results[0].Document.ID)
+	assert.InDelta(t, 1, results[0].Score, 1e-5)
+}
+
+func TestBinaryQuantizedReadOnlyStoreOfOlderVersion(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "store.skdb")
+	ctx := context.Background()
+	rng := rand.New(rand.NewSource(1))
+	docs := makeRandomDocuments(rng, 50, 64)
+
+	ds, err := NewDiskStore(file, WithQuantization(BinaryQuantization))
+	assert.NoError(t, err)
+	for _, doc := range docs {
+		assert.NoError(t, ds.Index(ctx, doc))
+	}
+	header := ds.header
+	assert.NoError(t, ds.Close())
+
+	// rewrite the store in the version 3 layout, whose records start a byte earlier
+	data, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	var old bytes.Buffer
+	assert.NoError(t, writeV3Header(&old, header))
+	old.Write(data[fileHeaderSize:])
+	assert.NoError(t, os.WriteFile(file, old.Bytes(), 0o644))
+
+	// the store is only migrated in memory, where full embeddings are read back from for rescoring
+	ds, err = NewDiskStore(file, WithLock(SharedLock, 0))
+	assert.NoError(t, err)
+	defer ds.Close()
+
+	results, err := ds.Search(ctx, docs[7].Chunks[0].Embedding, 1)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, docs[7].ID, results[0].Document.ID)
+	assert.InDelta(t, 1, results[0].Score, 1e-5)
+
+	data, err = os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, old.Bytes(), data)
+}
+
+func TestQuantizedStoreRefusesVectorIndex(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "store.skdb")
+	_, err := NewDiskStore(file, WithQuantization(ScalarQuantization), WithIndex(HNSWIndex))
+	assert.Error(t, err)
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/jnaraujo/seekr/internal/document"
+)
+
+// The store file is a header followed by an append-only sequence of records:
+//
+//	kind (1 byte) | payload length (4 bytes, LE) | crc32 of kind+payload (4 bytes, LE) | payload
+//
+// An add record carries a gob-encoded document.Document, a remove record carries
+// the raw document ID. Replaying the records in order rebuilds the live set.
+
+const (
+	recordHeaderSize = 1 + 4 + 4
+	// maxRecordSize guards against allocating absurd buffers when a length prefix is corrupted.
+	maxRecordSize = 1 << 30
+)
+
+type recordKind byte
+
+const (
+	recordAdd    recordKind = 1
+	recordRemove recordKind = 2
+)
+
+var (
+	errCorruptRecord    = errors.New("corrupt record")
+	errIncompleteRecord = errors.New("incomplete record")
+)
+
+type record struct {
+	kind    recordKind
+	payload []byte
+}
+
+func recordChecksum(kind recordKind, payload []byte) uint32 {
+	crc := crc32.Update(0, crc32.IEEETable, []byte{byte(kind)})
+	return crc32.Update(crc, crc32.IEEETable, payload)
+}
+
+// encodeRecord returns the on-disk representation of a record.
+func encodeRecord(kind recordKind, payload []byte) []byte {
+	buf := make([]byte, recordHeaderSize+len(payload))
+	buf[0] = byte(kind)
+	binary.LittleEndian.PutUint32(buf[1:5], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[5:9], recordChecksum(kind, payload))
+	copy(buf[recordHeaderSize:], payload)
+	return buf
+}
+
+// parseRecord parses the record at the start of data. It returns errIncompleteRecord
+// if data ends before the record does and errCorruptRecord if the record fails verification.
+func parseRecord(data []byte) (record, int64, error) {
+	if len(data) < recordHeaderSize {
+		return record{}, 0, errIncompleteRecord
+	}
+
+	kind := recordKind(data[0])
+	length := binary.LittleEndian.Uint32(data[1:5])
+	checksum := binary.LittleEndian.Uint32(data[5:9])
+
+	if kind != recordAdd && kind != recordRemove {
+		return record{}, 0, fmt.Errorf("%w: unknown record kind %d", errCorruptRecord, kind)
+	}
+	if length > maxRecordSize {
+		return record{}, 0, fmt.Errorf("%w: record length %d is too large", errCorruptRecord, length)
+	}
+
+	size := int64(recordHeaderSize) + int64(length)
+	if int64(len(data)) < size {
+		return record{}, 0, errIncompleteRecord
+	}
+
+	payload := data[recordHeaderSize:size]
+	if recordChecksum(kind, payload) != checksum {
+		return record{}, 0, fmt.Errorf("%w: checksum mismatch", errCorruptRecord)
+	}
+
+	return record{kind: kind, payload: payload}, size, nil
+}
+
+// Problem describes a span of the store file that could not be used.
+type Problem struct {
+	Offset int64
+	Size   int64
+	Reason string
+}
+
+// walkRecords calls visit for every valid record in data, which starts at offset base in the file.
+// Records that fail verification, or that visit rejects, are reported as problems; after a
+// corrupt record, walking resumes at the next offset holding a valid record. It returns the
+// offset where the valid log ends, which is before any partially written trailing record.
+func walkRecords(data []byte, base int64, visit func(offset int64, rec record, size int64) error) (int64, []Problem) {
+	var problems []Problem
+	pos := 0
+	for pos < len(data) {
+		offset := base + int64(pos)
+		rec, n, err := parseRecord(data[pos:])
+		if err == nil {
+			if err := visit(offset, rec, n); err != nil {
+				problems = append(problems, Problem{Offset: offset, Size: n, Reason: err.Error()})
+			}
+			pos += int(n)
+			continue
+		}
+
+		next := nextRecord(data, pos+1)
+		if next == -1 {
+			if errors.Is(err, errIncompleteRecord) {
+				// nothing valid follows, so this is an append that never completed
+				return offset, problems
+			}
+			next = len(data)
+		}
+		problems = append(problems, Problem{Offset: offset, Size: int64(next - pos), Reason: err.Error()})
+		pos = next
+	}
+	return base + int64(len(data)), problems
+}
+
+// nextRecord returns the position of the first valid record at or after start, or -1.
+func nextRecord(data []byte, start int) int {
+	for i := start; i+recordHeaderSize <= len(data); i++ {
+		if _, _, err := parseRecord(data[i:]); err == nil {
+			return i
+		}
+	}
+	return -1
+}
+
+func encodeAddRecord(doc document.Document) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(doc); err != nil {
+		return nil, err
+	}
+	return encodeRecord(recordAdd, buf.Bytes()), nil
+}
+
+func encodeRemoveRecord(id string) []byte {
+	return encodeRecord(recordRemove, []byte(id))
+}
+
+func decodeDocument(payload []byte) (document.Document, error) {
+	var doc document.Document
+	err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&doc)
+	return doc, err
+}
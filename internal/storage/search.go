@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/jnaraujo/seekr/internal/document"
+)
+
+const (
+	// minDocsPerWorker keeps small stores from paying for goroutines they do not need.
+	minDocsPerWorker = 512
+	// cancelCheckInterval is how many documents a worker scores between checks of the context.
+	cancelCheckInterval = 256
+)
+
+// topDocuments scores every document and returns the limit best, best first, as candidates
+// holding their position in ds.documents. The documents are split into contiguous shards
+// scanned in parallel, each worker keeping its best limit documents in a bounded min-heap,
+// and the heaps are merged at the end. It stops early when ctx is cancelled.
+func (ds *DiskStore) topDocuments(ctx context.Context, limit int, score func(doc document.Document) (float32, int)) ([]candidate, error) {
+	docs := ds.documents
+	workers := max(1, min(ds.searchWorkers, (len(docs)+minDocsPerWorker-1)/minDocsPerWorker))
+	size := (len(docs) + workers - 1) / workers
+
+	heaps := make([]candidateHeap, workers)
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for w := range workers {
+		start, end := w*size, min((w+1)*size, len(docs))
+		if start >= end {
+			break
+		}
+		wg.Add(1)
+		go func(w, start, end int) {
+			defer wg.Done()
+			best := &heaps[w]
+			for i := start; i < end; i++ {
+				if (i-start)%cancelCheckInterval == 0 {
+					if err := ctx.Err(); err != nil {
+						errs[w] = err
+						return
+					}
+				}
+				if len(docs[i].Chunks) == 0 {
+					continue
+				}
+				s, _ := score(docs[i])
+				if best.len() < limit || s > best.top().score {
+					best.push(candidate{id: int32(i), score: s})
+					if best.len() > limit {
+						best.pop()
+					}
+				}
+			}
+		}(w, start, end)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	merged := &candidateHeap{}
+	for _, h := range heaps {
+		for _, c := range h.items {
+			if merged.len() < limit || c.score > merged.top().score {
+				merged.push(c)
+				if merged.len() > limit {
+					merged.pop()
+				}
+			}
+		}
+	}
+
+	found := merged.items
+	slices.SortFunc(found, compareCandidates)
+	return found, nil
+}
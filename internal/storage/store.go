@@ -7,7 +7,18 @@ import (
 	"github.com/jnaraujo/seekr/internal/document"
 )
 
-var ErrNotFound = errors.New("document not found")
+var (
+	ErrNotFound = errors.New("document not found")
+	ErrCorrupt  = errors.New("store is corrupt")
+	ErrReadOnly = errors.New("store is opened read-only")
+	ErrNoIndex  = errors.New("store has no vector index")
+	// ErrEmbeddingMismatch is returned when embeddings of another model or dimension are mixed
+	// with the ones the store holds.
+	ErrEmbeddingMismatch = errors.New("embeddings do not match the store")
+	// ErrIndexMismatch is returned when opening a store with another vector index than the one
+	// it records.
+	ErrIndexMismatch = errors.New("vector index does not match the store")
+)
 
 type SearchResult struct {
 	Document          document.Document
@@ -29,3 +40,25 @@ type Store interface {
 	// Closes the store
 	Close() error
 }
+
+type CompactStats struct {
+	BytesBefore int64
+	BytesAfter  int64
+}
+
+// Reclaimed returns how many bytes the compaction freed.
+func (s CompactStats) Reclaimed() int64 {
+	return s.BytesBefore - s.BytesAfter
+}
+
+// Compactor is implemented by stores that keep removed documents on disk until compacted.
+type Compactor interface {
+	// Compact rewrites the store without removed or superseded documents.
+	Compact(ctx context.Context) (CompactStats, error)
+}
+
+// IndexRebuilder is implemented by stores that search through a vector index built over time.
+type IndexRebuilder interface {
+	// RebuildIndex builds the index again from scratch, retraining it if it needs training.
+	RebuildIndex(ctx context.Context) error
+}
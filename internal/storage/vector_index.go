@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jnaraujo/seekr/internal/document"
+)
+
+// IndexKind selects how DiskStore.Search finds the chunks closest to a query.
+type IndexKind string
+
+const (
+	// BruteForceIndex compares the query against every chunk of every document.
+	BruteForceIndex IndexKind = "brute"
+	// HNSWIndex searches a hierarchical navigable small world graph.
+	HNSWIndex IndexKind = "hnsw"
+	// IVFIndex searches the closest clusters of a k-means partition of the chunks.
+	IVFIndex IndexKind = "ivf"
+)
+
+func ParseIndexKind(s string) (IndexKind, error) {
+	switch kind := IndexKind(s); kind {
+	case BruteForceIndex, HNSWIndex, IVFIndex:
+		return kind, nil
+	}
+	return "", fmt.Errorf("unknown index %q", s)
+}
+
+func (k IndexKind) code() byte {
+	switch k {
+	case HNSWIndex:
+		return 1
+	case IVFIndex:
+		return 2
+	}
+	return 0
+}
+
+func indexKindFromCode(code byte) (IndexKind, bool) {
+	switch code {
+	case 0:
+		return BruteForceIndex, true
+	case 1:
+		return HNSWIndex, true
+	case 2:
+		return IVFIndex, true
+	}
+	return "", false
+}
+
+// chunkRef identifies a chunk of a stored document.
+type chunkRef struct {
+	DocID string
+	Chunk int
+}
+
+type chunkHit struct {
+	ref   chunkRef
+	score float32
+}
+
+// vectorIndex is an approximate nearest neighbour index over the chunks of the stored documents.
+// It is kept in memory next to the documents and saved to a file alongside the store.
+type vectorIndex interface {
+	// add indexes every chunk of doc, replacing the chunks of a document with the same ID.
+	add(doc document.Document)
+	remove(id string)
+	// search returns up to k chunks most similar to the normalized query, best first.
+	// It fails with the error of ctx once ctx is cancelled.
+	search(ctx context.Context, query []float32, k int) ([]chunkHit, error)
+	// sync brings an index loaded from disk in line with the documents in the store.
+	sync(docs []document.Document)
+	// build discards the index and builds it again from docs.
+	build(docs []document.Document)
+	// ready reports whether the index can answer searches; the store scans every chunk otherwise.
+	ready() bool
+	// dirty reports whether the index changed since it was loaded or saved.
+	dirty() bool
+	save(path string) error
+}
+
+// syncDocs brings idx in line with docs, given when each document it holds was created:
+// documents that are gone or were indexed again since are removed, missing ones are added.
+func syncDocs(idx vectorIndex, indexed map[string]time.Time, docs []document.Document) {
+	live := make(map[string]document.Document, len(docs))
+	for _, doc := range docs {
+		live[doc.ID] = doc
+	}
+
+	for id, createdAt := range indexed {
+		if doc, ok := live[id]; !ok || !doc.CreatedAt.Equal(createdAt) {
+			idx.remove(id)
+			delete(indexed, id)
+		}
+	}
+	for _, doc := range docs {
+		if _, ok := indexed[doc.ID]; !ok {
+			idx.add(doc)
+		}
+	}
+}
+
+// searchIndex finds the topK documents whose best chunk is closest to the query through ds.index.
+// Documents have several chunks, so it keeps asking the index for more chunks until it has seen
+// topK distinct documents or the index has nothing more to return.
+func (ds *DiskStore) searchIndex(ctx context.Context, query []float32, topK int) ([]SearchResult, error) {
+	k := topK * 4
+	var best map[string]chunkHit
+	for {
+		hits, err := ds.index.search(ctx, query, k)
+		if err != nil {
+			return nil, err
+		}
+		best = make(map[string]chunkHit, len(hits))
+		for _, hit := range hits {
+			if cur, ok := best[hit.ref.DocID]; !ok || hit.score > cur.score {
+				best[hit.ref.DocID] = hit
+			}
+		}
+		if len(best) >= topK || len(hits) < k {
+			break
+		}
+		k *= 2
+	}
+
+	results := make([]SearchResult, 0, len(best))
+	for _, doc := range ds.documents {
+		hit, ok := best[doc.ID]
+		if !ok || hit.score <= 0 {
+			continue
+		}
+		results = append(results, SearchResult{Document: doc, Score: hit.score, BestMatchingChunk: hit.ref.Chunk})
+	}
+
+	sortResults(results)
+	return results[:min(topK, len(results))], nil
+}
+
+type candidate struct {
+	id    int32
+	score float32
+}
+
+// compareCandidates orders candidates best first.
+func compareCandidates(a, b candidate) int {
+	switch {
+	case a.score > b.score:
+		return -1
+	case a.score < b.score:
+		return 1
+	}
+	return 0
+}
+
+// candidateHeap is a binary heap that pops the worst candidate first, or the best one when best is set.
+type candidateHeap struct {
+	items []candidate
+	best  bool
+}
+
+func (h *candidateHeap) len() int { return len(h.items) }
+
+func (h *candidateHeap) top() candidate { return h.items[0] }
+
+func (h *candidateHeap) before(i, j int) bool {
+	if h.best {
+		return h.items[i].score > h.items[j].score
+	}
+	return h.items[i].score < h.items[j].score
+}
+
+func (h *candidateHeap) push(c candidate) {
+	h.items = append(h.items, c)
+	for i := len(h.items) - 1; i > 0; {
+		parent := (i - 1) / 2
+		if !h.before(i, parent) {
+			break
+		}
+		h.items[i], h.items[parent] = h.items[parent], h.items[i]
+		i = parent
+	}
+}
+
+func (h *candidateHeap) pop() candidate {
+	top := h.items[0]
+	last := len(h.items) - 1
+	h.items[0] = h.items[last]
+	h.items = h.items[:last]
+
+	for i := 0; ; {
+		next := i
+		if l := 2*i + 1; l < last && h.before(l, next) {
+			next = l
+		}
+		if r := 2*i + 2; r < last && h.before(r, next) {
+			next = r
+		}
+		if next == i {
+			break
+		}
+		h.items[i], h.items[next] = h.items[next], h.items[i]
+		i = next
+	}
+	return top
+}
@@ -0,0 +1,507 @@
+package textsplitter
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Language is how the CodeTextSplitter finds the declarations of a source file.
+type Language int
+
+const (
+	// NoLanguage is the language of files that are not source code.
+	NoLanguage Language = iota
+	// GoLanguage files are parsed, and chunked by top-level declaration.
+	GoLanguage
+	// IndentLanguage covers languages whose blocks are set by indentation, such as Python.
+	IndentLanguage
+	// BraceLanguage covers languages whose blocks are delimited by braces, such as TypeScript,
+	// JavaScript, Java, C or Rust.
+	BraceLanguage
+)
+
+var languages = map[string]Language{
+	".go":    GoLanguage,
+	".py":    IndentLanguage,
+	".pyi":   IndentLanguage,
+	".pyw":   IndentLanguage,
+	".ts":    BraceLanguage,
+	".tsx":   BraceLanguage,
+	".mts":   BraceLanguage,
+	".cts":   BraceLanguage,
+	".js":    BraceLanguage,
+	".jsx":   BraceLanguage,
+	".mjs":   BraceLanguage,
+	".cjs":   BraceLanguage,
+	".java":  BraceLanguage,
+	".kt":    BraceLanguage,
+	".kts":   BraceLanguage,
+	".scala": BraceLanguage,
+	".c":     BraceLanguage,
+	".h":     BraceLanguage,
+	".cc":    BraceLanguage,
+	".cpp":   BraceLanguage,
+	".cxx":   BraceLanguage,
+	".hpp":   BraceLanguage,
+	".cs":    BraceLanguage,
+	".rs":    BraceLanguage,
+	".swift": BraceLanguage,
+	".php":   BraceLanguage,
+	".dart":  BraceLanguage,
+}
+
+// LanguageOf returns the language of the source file at path, picked by its extension, or
+// NoLanguage if it is not one the CodeTextSplitter knows.
+func LanguageOf(path string) Language {
+	return languages[strings.ToLower(filepath.Ext(path))]
+}
+
+// CodeTextSplitter splits source code into its top-level declarations, so that a function is never
+// cut from its signature or its doc comment. Go files are parsed, while the declarations of other
+// languages are found by following their braces or their indentation. Every chunk is given the
+// name of the declaration it holds. Declarations longer than a chunk are split like the
+// RecursiveCharacterTextSplitter does, and chunks overlap only within such splits.
+type CodeTextSplitter struct {
+	TextSplitter
+	language Language
+}
+
+func NewCodeTextSplitter(language Language, chunkSize int, chunkOverlap int, opts ...Option) *CodeTextSplitter {
+	return &CodeTextSplitter{
+		TextSplitter: newTextSplitter(chunkSize, chunkOverlap, opts),
+		language:     language,
+	}
+}
+
+// codeUnit is a declaration lying between the byte offsets start and end of the text, along with
+// the comments before it.
+type codeUnit struct {
+	start, end int
+	symbol     string
+}
+
+// Split splits text into chunks, each along with where it lies in text and the name of its declaration.
+func (c *CodeTextSplitter) Split(text string) []Segment {
+	var units []codeUnit
+	switch c.language {
+	case GoLanguage:
+		var ok bool
+		if units, ok = goUnits(text); !ok {
+			// a file that does not parse is still split, by its braces
+			units = braceUnits(text)
+		}
+	case IndentLanguage:
+		units = indentUnits(text)
+	default:
+		units = braceUnits(text)
+	}
+
+	lines := lineStarts(text)
+	var segments []Segment
+	for _, unit := range units {
+		start, end := trimRange(text, unit.start, unit.end)
+		if start == end {
+			continue
+		}
+		if c.lengthFunction(text[start:end]) <= c.chunkSize {
+			segment := newSegment(text, lines, start, end, "")
+			segment.Symbol = unit.symbol
+			segments = append(segments, segment)
+			continue
+		}
+		for _, segment := range c.splitRange(text, lines, start, end) {
+			segment.Symbol = unit.symbol
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// trimRange shrinks text[start:end] to leave out the whitespace around it.
+func trimRange(text string, start, end int) (int, int) {
+	for start < end && unicode.IsSpace(rune(text[start])) {
+		start++
+	}
+	for end > start && unicode.IsSpace(rune(text[end-1])) {
+		end--
+	}
+	return start, end
+}
+
+// goUnits parses text as a Go file, returning its package clause and imports as a unit of their
+// own, followed by every top-level declaration. It reports false if text does not parse.
+func goUnits(text string) ([]codeUnit, bool) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", text, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, false
+	}
+	offset := func(pos token.Pos) int {
+		return fset.Position(pos).Offset
+	}
+
+	header := codeUnit{start: 0, end: offset(f.Name.End()), symbol: "package " + f.Name.Name}
+	decls := f.Decls
+	for len(decls) > 0 {
+		gen, ok := decls[0].(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			break
+		}
+		header.end = offset(gen.End())
+		decls = decls[1:]
+	}
+
+	units := []codeUnit{header}
+	for _, decl := range decls {
+		// the comments between two declarations go with the latter
+		units = append(units, codeUnit{
+			start:  units[len(units)-1].end,
+			end:    offset(decl.End()),
+			symbol: goSymbol(decl),
+		})
+	}
+	// as do those after the last one
+	units[len(units)-1].end = len(text)
+	return units, true
+}
+
+// goSymbol returns the name of a declaration, such as "Store.Search" for a method.
+func goSymbol(decl ast.Decl) string {
+	switch decl := decl.(type) {
+	case *ast.FuncDecl:
+		if decl.Recv == nil || len(decl.Recv.List) == 0 {
+			return decl.Name.Name
+		}
+		return goReceiverName(decl.Recv.List[0].Type) + "." + decl.Name.Name
+	case *ast.GenDecl:
+		var names []string
+		for _, spec := range decl.Specs {
+			switch spec := spec.(type) {
+			case *ast.TypeSpec:
+				names = append(names, spec.Name.Name)
+			case *ast.ValueSpec:
+				for _, name := range spec.Names {
+					names = append(names, name.Name)
+				}
+			}
+		}
+		return joinSymbols(names)
+	}
+	return ""
+}
+
+func goReceiverName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return goReceiverName(expr.X)
+	case *ast.IndexExpr:
+		return goReceiverName(expr.X)
+	case *ast.IndexListExpr:
+		return goReceiverName(expr.X)
+	case *ast.ParenExpr:
+		return goReceiverName(expr.X)
+	case *ast.Ident:
+		return expr.Name
+	}
+	return ""
+}
+
+// maxSymbols is the number of names listed for a declaration that declares several, such as a
+// block of constants.
+const maxSymbols = 3
+
+func joinSymbols(names []string) string {
+	if len(names) > maxSymbols {
+		names = append(names[:maxSymbols:maxSymbols], "…")
+	}
+	return strings.Join(names, ", ")
+}
+
+var (
+	// braceDeclaration finds the name given by a declaration keyword, such as "class Store".
+	braceDeclaration = regexp.MustCompile(`\b(?:function\*?|class|interface|enum|type|struct|union|trait|namespace|module|object|record|fn|func|const|let|var|val)\s+([A-Za-z_$][\w$]*)`)
+	// braceFunction finds the name of a function declared without a keyword, such as "int main(void)".
+	braceFunction = regexp.MustCompile(`([A-Za-z_$][\w$]*)\s*(?:<[^()]*>)?\s*\(`)
+	// braceImpl finds the type of a Rust impl block, such as "Store" in "impl<T> Search for Store<T>".
+	braceImpl = regexp.MustCompile(`^impl\b(?:\s*<[^{]*?>)?\s+(?:[\w:<>, ]+\s+for\s+)?([A-Za-z_][\w]*)`)
+)
+
+// braceKeywords are the words that open a statement, not a function.
+var braceKeywords = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "catch": true, "return": true,
+	"sizeof": true, "typeof": true, "new": true, "await": true, "function": true,
+}
+
+// braceSymbol returns the name declared on a line of a brace language, or "" if there is none.
+func braceSymbol(line string) string {
+	line = strings.TrimSpace(line)
+	// decorators, attributes and preprocessor directives precede declarations
+	if strings.HasPrefix(line, "@") || strings.HasPrefix(line, "#") {
+		return ""
+	}
+	if m := braceImpl.FindStringSubmatch(line); m != nil {
+		return m[1]
+	}
+	if m := braceDeclaration.FindStringSubmatch(line); m != nil {
+		return m[1]
+	}
+	for _, m := range braceFunction.FindAllStringSubmatch(line, -1) {
+		if !braceKeywords[m[1]] {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// braceUnits splits the code of a brace language into its top-level statements. A statement ends
+// with the line closing its outermost braces or brackets, or at a blank line outside of them, and
+// the comments before a statement go with it. Braces within strings and comments are ignored,
+// although strings other than template literals are expected to end on the line they start.
+func braceUnits(text string) []codeUnit {
+	var (
+		units []codeUnit
+		unit  = codeUnit{start: 0}
+		// hasCode is whether the unit holds more than comments
+		hasCode      bool
+		depth        int
+		blockComment bool
+		template     bool
+		// decorator is whether the last line the unit started outside braces was a decorator
+		decorator bool
+	)
+
+	for lineStart := 0; lineStart < len(text); {
+		lineEnd, next := lineBounds(text, lineStart)
+		line := text[lineStart:lineEnd]
+		depthBefore := depth
+		code, comment := false, blockComment || template
+
+	scan:
+		for i := 0; i < len(line); i++ {
+			switch ch := line[i]; {
+			case blockComment:
+				if strings.HasPrefix(line[i:], "*/") {
+					blockComment = false
+					i++
+				}
+			case template:
+				if ch == '\\' {
+					i++
+				} else if ch == '`' {
+					template = false
+				}
+			case ch == ' ' || ch == '\t' || ch == '\r':
+			case strings.HasPrefix(line[i:], "//"):
+				comment = true
+				break scan
+			case strings.HasPrefix(line[i:], "/*"):
+				comment, blockComment = true, true
+				i++
+			case ch == '`':
+				code, template = true, true
+			case ch == '"' || ch == '\'':
+				code = true
+				if end := closingQuote(line, i); end > 0 {
+					i = end
+				}
+			case ch == '{' || ch == '(' || ch == '[':
+				code = true
+				depth++
+			case ch == '}' || ch == ')' || ch == ']':
+				code = true
+				depth = max(depth-1, 0)
+			default:
+				code = true
+			}
+		}
+
+		switch {
+		case !code && !comment && depthBefore == 0 && hasCode:
+			// a blank line between statements
+			unit.end = lineStart
+			units = append(units, unit)
+			unit, hasCode = codeUnit{start: lineStart}, false
+		case code:
+			if depthBefore == 0 {
+				decorator = strings.HasPrefix(strings.TrimSpace(line), "@")
+				if unit.symbol == "" {
+					unit.symbol = braceSymbol(line)
+				}
+			}
+			hasCode = true
+			// a decorator goes on with what it decorates, even when its arguments span lines
+			if depthBefore > 0 && depth == 0 && !decorator {
+				unit.end = next
+				units = append(units, unit)
+				unit, hasCode = codeUnit{start: next}, false
+			}
+		}
+		lineStart = next
+	}
+
+	unit.end = len(text)
+	if len(units) > 0 && !hasCode {
+		// the comments after the last statement go with it
+		units[len(units)-1].end = len(text)
+	} else {
+		units = append(units, unit)
+	}
+	return units
+}
+
+// closingQuote returns the offset of the quote closing the string opened at line[start], or -1 if
+// it is not closed on the line, as with Rust lifetimes, which are not strings.
+func closingQuote(line string, start int) int {
+	quote := line[start]
+	for i := start + 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// lineBounds returns where the line starting at lineStart ends, before its line break, and where
+// the next one starts.
+func lineBounds(text string, lineStart int) (int, int) {
+	end := strings.IndexByte(text[lineStart:], '\n')
+	if end < 0 {
+		return len(text), len(text)
+	}
+	return lineStart + end, lineStart + end + 1
+}
+
+// indentDefinition finds the name of a Python function or class.
+var indentDefinition = regexp.MustCompile(`^(?:async\s+)?(?:def|class)\s+([A-Za-z_]\w*)`)
+
+// indentUnits splits the code of an indentation language into its top-level definitions, each
+// with its decorators and the comments before it. The statements between definitions, such as
+// imports, are kept together. Lines within brackets, triple-quoted strings or continued with a
+// backslash are part of the line they continue.
+func indentUnits(text string) []codeUnit {
+	const (
+		statements = iota
+		decorators
+		definition
+	)
+	var (
+		units []codeUnit
+		unit  = codeUnit{start: 0}
+		kind  = statements
+		// commentStart is where the comments right before the current line start, or -1
+		commentStart = -1
+		depth        int
+		triple       string
+		continued    bool
+	)
+
+	for lineStart := 0; lineStart < len(text); {
+		lineEnd, next := lineBounds(text, lineStart)
+		line := strings.TrimRight(text[lineStart:lineEnd], "\r")
+		continuation := depth > 0 || triple != "" || continued
+
+		switch {
+		case continuation || strings.TrimSpace(line) == "":
+		case line[0] == ' ' || line[0] == '\t':
+			// the comments were within the body of the definition
+			commentStart = -1
+		case line[0] == '#':
+			if commentStart < 0 {
+				commentStart = lineStart
+			}
+		default:
+			start := lineStart
+			if commentStart >= 0 {
+				start = commentStart
+			}
+			symbol := ""
+			if m := indentDefinition.FindStringSubmatch(line); m != nil {
+				symbol = m[1]
+			}
+			lineKind := statements
+			switch {
+			case line[0] == '@':
+				lineKind = decorators
+			case symbol != "":
+				lineKind = definition
+			}
+
+			// a definition ends at the next top-level line, and statements at the next definition
+			var startsUnit bool
+			switch kind {
+			case definition:
+				startsUnit = true
+			case statements:
+				startsUnit = lineKind != statements && hasStatements(text[unit.start:start])
+			}
+			if startsUnit {
+				unit.end = start
+				units = append(units, unit)
+				unit = codeUnit{start: start}
+			}
+			if lineKind == definition {
+				unit.symbol = symbol
+			}
+			kind = lineKind
+			commentStart = -1
+		}
+
+		depth, triple, continued = scanIndentLine(line, depth, triple)
+		lineStart = next
+	}
+
+	unit.end = len(text)
+	return append(units, unit)
+}
+
+// hasStatements reports whether code holds more than blank lines and comments.
+func hasStatements(code string) bool {
+	for _, line := range strings.Split(code, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			return true
+		}
+	}
+	return false
+}
+
+// scanIndentLine follows the brackets and triple-quoted strings of a line of Python, returning how
+// many brackets are left open, the quotes of the triple-quoted string left open, and whether the
+// line is continued with a backslash.
+func scanIndentLine(line string, depth int, triple string) (int, string, bool) {
+	for i := 0; i < len(line); i++ {
+		if triple != "" {
+			if line[i] == '\\' {
+				i++
+			} else if strings.HasPrefix(line[i:], triple) {
+				i += len(triple) - 1
+				triple = ""
+			}
+			continue
+		}
+
+		switch ch := line[i]; ch {
+		case '#':
+			return depth, triple, false
+		case '"', '\'':
+			if quotes := line[i : i+1]; strings.HasPrefix(line[i:], quotes+quotes+quotes) {
+				triple = quotes + quotes + quotes
+				i += 2
+			} else if end := closingQuote(line, i); end > 0 {
+				i = end
+			}
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth = max(depth-1, 0)
+		}
+	}
+	return depth, triple, triple == "" && strings.HasSuffix(line, "\\")
+}
@@ -0,0 +1,197 @@
+package textsplitter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const goSource = `// Package store keeps documents.
+package store
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound is returned for missing documents.
+var ErrNotFound = errors.New("not found")
+
+const (
+	A = iota
+	B
+	C
+	D
+)
+
+type Store[T any] struct {
+	docs map[string]T
+}
+
+// Search looks for a document.
+//
+// It never fails.
+func (s *Store[T]) Search(ctx context.Context, query string) (T, error) {
+	doc, ok := s.docs[query]
+	if !ok {
+		return doc, ErrNotFound
+	}
+
+	return doc, nil
+}
+
+func helper() {}
+
+// trailing comment
+`
+
+// symbols returns the symbol of every segment, checking where the segment lies in text.
+func symbols(t *testing.T, text string, segments []Segment) []string {
+	t.Helper()
+	var names []string
+	for _, seg := range segments {
+		names = append(names, seg.Symbol)
+		assert.Equal(t, seg.Text, text[seg.Start:seg.End])
+		assert.Equal(t, strings.Count(text[:seg.Start], "\n")+1, seg.StartLine)
+		assert.Equal(t, strings.Count(text[:seg.End], "\n")+1, seg.EndLine)
+	}
+	return names
+}
+
+func TestCodeSplitsGoByDeclaration(t *testing.T) {
+	segments := NewCodeTextSplitter(GoLanguage, 1000, 0).Split(goSource)
+
+	assert.Equal(t, []string{"package store", "ErrNotFound", "A, B, C, …", "Store", "Store.Search", "helper"},
+		symbols(t, goSource, segments))
+
+	search := segments[4]
+	assert.True(t, strings.HasPrefix(search.Text, "// Search looks for a document."))
+	assert.True(t, strings.HasSuffix(search.Text, "return doc, nil\n}"))
+	assert.Equal(t, 23, search.StartLine)
+	assert.Equal(t, 33, search.EndLine)
+	assert.Equal(t, "func helper() {}\n\n// trailing comment", segments[5].Text)
+}
+
+func TestCodeSplitsLongDeclarations(t *testing.T) {
+	segments := NewCodeTextSplitter(GoLanguage, 60, 0).Split(goSource)
+
+	var search []Segment
+	for _, seg := range segments {
+		assert.LessOrEqual(t, RuneLength(seg.Text), 60)
+		if seg.Symbol == "Store.Search" {
+			search = append(search, seg)
+		}
+	}
+	assert.Greater(t, len(search), 1)
+	assert.Equal(t, 23, search[0].StartLine)
+	assert.Equal(t, 33, search[len(search)-1].EndLine)
+}
+
+func TestCodeFallsBackToBracesForInvalidGo(t *testing.T) {
+	text := "package broken\n\nfunc Open() {\n\tif {\n}\n\nfunc Close() {\n}\n"
+	segments := NewCodeTextSplitter(GoLanguage, 1000, 0).Split(text)
+	assert.Equal(t, []string{"", "Open"}, symbols(t, text, segments))
+}
+
+const typeScriptSource = `import { readFile } from "fs";
+import path from "path";
+
+/**
+ * Loads the config { from disk }.
+ */
+export async function loadConfig(file: string): Promise<Config> {
+  const raw = await readFile(path.resolve(file), "utf8");
+
+  return JSON.parse(raw.replace("}", ""));
+}
+export class Store<T> extends Base {
+  private docs = new Map<string, T>();
+
+  search(query: string): T | undefined {
+    return this.docs.get(` + "`${query}`" + `);
+  }
+}
+
+@Component({
+  selector: "app-root",
+})
+export class AppComponent {}
+
+export default defineConfig({
+  plugins: [],
+});
+`
+
+func TestCodeSplitsBraceLanguages(t *testing.T) {
+	segments := NewCodeTextSplitter(BraceLanguage, 1000, 0).Split(typeScriptSource)
+
+	assert.Equal(t, []string{"", "loadConfig", "Store", "AppComponent", "defineConfig"},
+		symbols(t, typeScriptSource, segments))
+	assert.True(t, strings.HasPrefix(segments[1].Text, "/**\n * Loads the config"))
+	assert.True(t, strings.HasSuffix(segments[1].Text, "\"\"));\n}"))
+	assert.True(t, strings.HasPrefix(segments[3].Text, "@Component({"))
+}
+
+func TestBraceSymbol(t *testing.T) {
+	tests := map[string]string{
+		"public static void main(String[] args) {": "main",
+		"int main(void)": "main",
+		"pub fn search<'a>(query: &'a str) -> Vec<&'a str> {": "search",
+		"impl<T: Clone> Search for Store<T> {":                "Store",
+		"export const handler = async (event) => {":           "handler",
+		"interface Config {":                                  "Config",
+		"#include <stdio.h>":                                  "",
+		"if (ready) {":                                        "",
+	}
+	for line, want := range tests {
+		assert.Equal(t, want, braceSymbol(line), line)
+	}
+}
+
+const pythonSource = `"""Utilities for the store."""
+import os
+
+DEFAULT = 3
+
+
+# Loads a document.
+@cache
+@retry(
+    times=3,
+)
+def load(path):
+    """Loads path.
+
+def not_a_definition():
+"""
+    with open(path) as f:
+# a comment within the body
+        return f.read()
+
+
+class Store:
+    def search(self, query):
+        return [
+            query,
+        ]
+
+main = Store()
+`
+
+func TestCodeSplitsIndentLanguages(t *testing.T) {
+	segments := NewCodeTextSplitter(IndentLanguage, 1000, 0).Split(pythonSource)
+
+	assert.Equal(t, []string{"", "load", "Store", ""}, symbols(t, pythonSource, segments))
+	assert.Equal(t, "\"\"\"Utilities for the store.\"\"\"\nimport os\n\nDEFAULT = 3", segments[0].Text)
+	assert.True(t, strings.HasPrefix(segments[1].Text, "# Loads a document.\n@cache"))
+	assert.True(t, strings.HasSuffix(segments[1].Text, "return f.read()"))
+	assert.Equal(t, "main = Store()", segments[3].Text)
+}
+
+func TestLanguageOf(t *testing.T) {
+	assert.Equal(t, GoLanguage, LanguageOf("/src/store.go"))
+	assert.Equal(t, IndentLanguage, LanguageOf("main.py"))
+	assert.Equal(t, BraceLanguage, LanguageOf("App.TSX"))
+	assert.Equal(t, NoLanguage, LanguageOf("notes.md"))
+}
@@ -0,0 +1,10 @@
+package textsplitter
+
+import "unicode/utf8"
+
+var (
+	// ByteLength measures texts in bytes.
+	ByteLength LenFunction = func(s string) int { return len(s) }
+	// RuneLength measures texts in characters, so that multibyte text gets chunks as long as ASCII text.
+	RuneLength LenFunction = utf8.RuneCountInString
+)
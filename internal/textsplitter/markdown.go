@@ -0,0 +1,252 @@
+package textsplitter
+
+import "strings"
+
+// breadcrumbSeparator joins the headings of a breadcrumb.
+const breadcrumbSeparator = " > "
+
+// MarkdownTextSplitter splits Markdown along its structure: every section under a heading is
+// chunked on its own, paragraphs, tables and fenced code blocks are kept whole as long as they fit
+// a chunk, and every chunk is given the breadcrumb of the headings it lies under. Code blocks are
+// never split, even when they are longer than a chunk, while longer paragraphs and tables are
+// split like the RecursiveCharacterTextSplitter does. Chunks overlap only within such splits.
+type MarkdownTextSplitter struct {
+	TextSplitter
+}
+
+func NewMarkdownTextSplitter(chunkSize int, chunkOverlap int, opts ...Option) *MarkdownTextSplitter {
+	return &MarkdownTextSplitter{TextSplitter: newTextSplitter(chunkSize, chunkOverlap, opts)}
+}
+
+type markdownBlockKind int
+
+const (
+	paragraphBlock markdownBlockKind = iota
+	tableBlock
+	fenceBlock
+	headingBlock
+)
+
+// markdownBlock lies between the byte offsets start and end of the text, from the start of its
+// first line to the end of its last one.
+type markdownBlock struct {
+	kind       markdownBlockKind
+	start, end int
+}
+
+type markdownSection struct {
+	breadcrumb string
+	blocks     []markdownBlock
+}
+
+type markdownHeading struct {
+	level int
+	text  string
+}
+
+// Split splits text into chunks, each along with where it lies in text and its breadcrumb.
+func (m *MarkdownTextSplitter) Split(text string) []Segment {
+	lines := lineStarts(text)
+	var segments []Segment
+	for _, section := range parseMarkdown(text) {
+		segments = append(segments, m.splitSection(text, lines, section)...)
+	}
+	return segments
+}
+
+func (m *MarkdownTextSplitter) splitSection(text string, lines []int, section markdownSection) []Segment {
+	// a heading followed right away by a deeper one only shows in the breadcrumbs of the latter
+	if len(section.blocks) == 1 && section.blocks[0].kind == headingBlock {
+		return nil
+	}
+
+	var segments []Segment
+	start, end := -1, -1
+	flush := func() {
+		if start >= 0 {
+			segments = append(segments, newSegment(text, lines, start, end, section.breadcrumb))
+			start = -1
+		}
+	}
+
+	for _, block := range section.blocks {
+		if block.kind != fenceBlock && m.lengthFunction(text[block.start:block.end]) > m.chunkSize {
+			flush()
+			segments = append(segments, m.splitBlock(text, lines, block, section.breadcrumb)...)
+			continue
+		}
+		if start >= 0 && m.lengthFunction(text[start:block.end]) > m.chunkSize {
+			flush()
+		}
+		if start < 0 {
+			start = block.start
+		}
+		end = block.end
+	}
+	flush()
+	return segments
+}
+
+// splitBlock splits a paragraph or table too long for a chunk with the recursive splitter.
+func (m *MarkdownTextSplitter) splitBlock(text string, lines []int, block markdownBlock, breadcrumb string) []Segment {
+	segments := m.splitRange(text, lines, block.start, block.end)
+	for i := range segments {
+		segments[i].Breadcrumb = breadcrumb
+	}
+	return segments
+}
+
+// parseMarkdown splits text into sections at its headings, and every section into its blocks.
+func parseMarkdown(text string) []markdownSection {
+	var (
+		sections = []markdownSection{{}}
+		headings []markdownHeading
+		// open is the block being read, of open.kind and with openLines lines
+		open      = markdownBlock{start: -1}
+		openLines int
+		fence     string
+	)
+	closeBlock := func() {
+		if open.start >= 0 {
+			last := &sections[len(sections)-1]
+			last.blocks = append(last.blocks, open)
+			open.start = -1
+		}
+	}
+	startSection := func(heading markdownHeading, start, end int) {
+		for len(headings) > 0 && headings[len(headings)-1].level >= heading.level {
+			headings = headings[:len(headings)-1]
+		}
+		headings = append(headings, heading)
+
+		crumbs := make([]string, len(headings))
+		for i, h := range headings {
+			crumbs[i] = h.text
+		}
+		sections = append(sections, markdownSection{
+			breadcrumb: strings.Join(crumbs, breadcrumbSeparator),
+			blocks:     []markdownBlock{{kind: headingBlock, start: start, end: end}},
+		})
+	}
+
+	for lineStart := 0; lineStart < len(text); {
+		lineEnd := strings.IndexByte(text[lineStart:], '\n')
+		next := lineStart + lineEnd + 1
+		if lineEnd < 0 {
+			lineEnd = len(text)
+			next = len(text)
+		} else {
+			lineEnd += lineStart
+		}
+		line := strings.TrimRight(text[lineStart:lineEnd], "\r")
+		trimmed, indented := trimIndent(line)
+
+		switch {
+		case fence != "":
+			open.end = lineEnd
+			if !indented && isClosingFence(trimmed, fence) {
+				fence = ""
+				closeBlock()
+			}
+		case !indented && openingFence(trimmed) != "":
+			closeBlock()
+			fence = openingFence(trimmed)
+			open = markdownBlock{kind: fenceBlock, start: lineStart, end: lineEnd}
+		case !indented && atxHeadingLevel(trimmed) > 0:
+			closeBlock()
+			level := atxHeadingLevel(trimmed)
+			startSection(markdownHeading{level, atxHeadingText(trimmed, level)}, lineStart, lineEnd)
+		case !indented && open.start >= 0 && open.kind == paragraphBlock && openLines == 1 && setextLevel(trimmed) > 0:
+			heading := markdownHeading{setextLevel(trimmed), strings.TrimSpace(text[open.start:lineStart])}
+			start := open.start
+			open.start = -1
+			startSection(heading, start, lineEnd)
+		case strings.TrimSpace(line) == "":
+			closeBlock()
+		default:
+			kind := paragraphBlock
+			if strings.HasPrefix(trimmed, "|") {
+				kind = tableBlock
+			}
+			if open.start >= 0 && open.kind != kind {
+				closeBlock()
+			}
+			if open.start < 0 {
+				open = markdownBlock{kind: kind, start: lineStart}
+				openLines = 0
+			}
+			open.end = lineEnd
+			openLines++
+		}
+		lineStart = next
+	}
+	// a fence left open runs to the end of the text
+	closeBlock()
+
+	if len(sections[0].blocks) == 0 {
+		sections = sections[1:]
+	}
+	return sections
+}
+
+// trimIndent strips the up to three spaces Markdown allows before block markers, reporting
+// whether the line is indented further, which makes it part of an indented code block or a list.
+func trimIndent(line string) (string, bool) {
+	trimmed := strings.TrimLeft(line, " ")
+	return trimmed, len(line)-len(trimmed) > 3
+}
+
+// openingFence returns the fence a line opens, made of three or more backticks or tildes.
+func openingFence(line string) string {
+	if !strings.HasPrefix(line, "```") && !strings.HasPrefix(line, "~~~") {
+		return ""
+	}
+	n := len(line) - len(strings.TrimLeft(line, line[:1]))
+	// the info string of a backtick fence cannot hold backticks
+	if line[0] == '`' && strings.Contains(line[n:], "`") {
+		return ""
+	}
+	return line[:n]
+}
+
+// isClosingFence reports whether a line closes fence: at least as many of the same characters,
+// and nothing else.
+func isClosingFence(line, fence string) bool {
+	rest := strings.TrimLeft(line, fence[:1])
+	return len(line)-len(rest) >= len(fence) && strings.TrimSpace(rest) == ""
+}
+
+// atxHeadingLevel returns the level of a heading such as "## Linux", or 0 if the line is not one.
+func atxHeadingLevel(line string) int {
+	level := len(line) - len(strings.TrimLeft(line, "#"))
+	if level == 0 || level > 6 {
+		return 0
+	}
+	if len(line) > level && line[level] != ' ' && line[level] != '\t' {
+		return 0
+	}
+	return level
+}
+
+func atxHeadingText(line string, level int) string {
+	text := strings.TrimSpace(line[level:])
+	// an optional closing sequence of #s follows a space
+	if trimmed := strings.TrimRight(text, "#"); trimmed != text && (trimmed == "" || strings.HasSuffix(trimmed, " ")) {
+		text = strings.TrimSpace(trimmed)
+	}
+	return text
+}
+
+// setextLevel returns the level of the heading a line of = or - underlines, or 0 if it is not such a line.
+func setextLevel(line string) int {
+	line = strings.TrimSpace(line)
+	switch {
+	case line == "":
+		return 0
+	case strings.Trim(line, "=") == "":
+		return 1
+	case strings.Trim(line, "-") == "":
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,100 @@
+package textsplitter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const markdownNotes = `Intro line.
+
+# Setup
+
+Install the tools first.
+
+## Linux
+
+### Proxy
+
+Set HTTP_PROXY before running:
+
+` + "```sh" + `
+export HTTP_PROXY=http://proxy:3128
+
+# not a heading
+` + "```" + `
+
+| Variable | Value |
+|----------|-------|
+| PORT     | 3128  |
+
+Windows
+-------
+
+Use the installer.
+`
+
+func TestMarkdownBreadcrumbs(t *testing.T) {
+	segments := NewMarkdownTextSplitter(1000, 0).Split(markdownNotes)
+
+	var crumbs []string
+	for _, seg := range segments {
+		crumbs = append(crumbs, seg.Breadcrumb)
+		assert.Equal(t, seg.Text, markdownNotes[seg.Start:seg.End])
+		assert.Equal(t, strings.Count(markdownNotes[:seg.Start], "\n")+1, seg.StartLine)
+		assert.Equal(t, strings.Count(markdownNotes[:seg.End], "\n")+1, seg.EndLine)
+	}
+	// "## Linux" holds nothing but its subsection, so it only shows in its breadcrumb
+	assert.Equal(t, []string{"", "Setup", "Setup > Linux > Proxy", "Setup > Windows"}, crumbs)
+
+	proxy := segments[2]
+	assert.True(t, strings.HasPrefix(proxy.Text, "### Proxy"))
+	assert.Contains(t, proxy.Text, "# not a heading")
+	assert.True(t, strings.HasSuffix(proxy.Text, "| PORT     | 3128  |"))
+	assert.Equal(t, "Windows\n-------\n\nUse the installer.", segments[3].Text)
+}
+
+func TestMarkdownNeverSplitsCodeFences(t *testing.T) {
+	code := "```go\n" + strings.Repeat("fmt.Println(\"hello\")\n\n", 20) + "```"
+	text := "# Code\n\n" + strings.Repeat("Some words here. ", 10) + "\n\n" + code + "\n\nAfter the code."
+
+	segments := NewMarkdownTextSplitter(100, 10).Split(text)
+	var fenced []Segment
+	for _, seg := range segments {
+		assert.Equal(t, "Code", seg.Breadcrumb)
+		if strings.Contains(seg.Text, "```") {
+			fenced = append(fenced, seg)
+		}
+	}
+	assert.Len(t, fenced, 1)
+	assert.Equal(t, code, fenced[0].Text)
+}
+
+func TestMarkdownSplitsLongParagraphs(t *testing.T) {
+	paragraph := strings.Repeat("lorem ipsum dolor sit amet ", 20)
+	text := "# Long\n\n" + paragraph
+
+	segments := NewMarkdownTextSplitter(100, 20).Split(text)
+	assert.Greater(t, len(segments), 2)
+	for _, seg := range segments {
+		assert.LessOrEqual(t, RuneLength(seg.Text), 100)
+		assert.Equal(t, seg.Text, text[seg.Start:seg.End])
+		assert.Equal(t, "Long", seg.Breadcrumb)
+	}
+	assert.Equal(t, 3, segments[1].StartLine)
+}
+
+func TestMarkdownHeadings(t *testing.T) {
+	assert.Equal(t, 2, atxHeadingLevel("## Linux"))
+	assert.Equal(t, 1, atxHeadingLevel("#"))
+	assert.Equal(t, 0, atxHeadingLevel("#hashtag"))
+	assert.Equal(t, 0, atxHeadingLevel("####### too deep"))
+	assert.Equal(t, "Linux", atxHeadingText("## Linux ##", 2))
+	assert.Equal(t, "C#", atxHeadingText("## C#", 2))
+
+	assert.Equal(t, "````", openingFence("````markdown"))
+	assert.Equal(t, "", openingFence("``inline``"))
+	assert.True(t, isClosingFence("`````", "````"))
+	assert.False(t, isClosingFence("```", "````"))
+}
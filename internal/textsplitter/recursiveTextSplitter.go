@@ -7,8 +7,9 @@ import (
 )
 
 var (
-	defaultSeparators                 = []string{"\n\n", "\n", " ", ""}
-	defaultLengthFunction LenFunction = func(s string) int { return len(s) }
+	// the empty separator splits between runes, so that chunks are never cut in the middle of one
+	defaultSeparators     = []string{"\n\n", "\n", " ", ""}
+	defaultLengthFunction = RuneLength
 )
 
 type RecursiveCharacterTextSplitter struct {
@@ -16,14 +17,10 @@ type RecursiveCharacterTextSplitter struct {
 	separators []string
 }
 
-func NewRecursiveCharacterTextSplitter(chunkSize int, chunkOverlap int) *RecursiveCharacterTextSplitter {
+func NewRecursiveCharacterTextSplitter(chunkSize int, chunkOverlap int, opts ...Option) *RecursiveCharacterTextSplitter {
 	return &RecursiveCharacterTextSplitter{
-		TextSplitter: TextSplitter{
-			chunkSize:      chunkSize,
-			chunkOverlap:   chunkOverlap,
-			lengthFunction: defaultLengthFunction,
-		},
-		separators: defaultSeparators,
+		TextSplitter: newTextSplitter(chunkSize, chunkOverlap, opts),
+		separators:   defaultSeparators,
 	}
 }
 
@@ -32,13 +29,6 @@ func (r *RecursiveCharacterTextSplitter) WithSeparators(separators []string) *Re
 	return r
 }
 
-func (r *RecursiveCharacterTextSplitter) WithLengthFunction(
-	lengthFunction LenFunction,
-) *RecursiveCharacterTextSplitter {
-	r.lengthFunction = lengthFunction
-	return r
-}
-
 func (r *RecursiveCharacterTextSplitter) SplitText(text string) []string {
 	// Split incoming text and return chunks.
 	finalChunks := []string{}
@@ -84,3 +74,8 @@ func (r *RecursiveCharacterTextSplitter) SplitText(text string) []string {
 	}
 	return finalChunks
 }
+
+// Split splits text like SplitText, also returning where every chunk lies in text.
+func (r *RecursiveCharacterTextSplitter) Split(text string) []Segment {
+	return Locate(text, r.SplitText(text))
+}
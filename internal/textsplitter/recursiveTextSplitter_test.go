@@ -0,0 +1,41 @@
+package textsplitter
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitNeverCutsRunes(t *testing.T) {
+	text := strings.Repeat("日本語のテキスト", 50) + "\n\n" + strings.Repeat("ação é ótima ", 40)
+
+	for _, length := range []LenFunction{RuneLength, ByteLength} {
+		chunks := NewRecursiveCharacterTextSplitter(37, 5, WithLengthFunction(length)).SplitText(text)
+		assert.Greater(t, len(chunks), 1)
+		for _, chunk := range chunks {
+			assert.True(t, utf8.ValidString(chunk), chunk)
+			assert.LessOrEqual(t, length(chunk), 37)
+		}
+	}
+}
+
+func TestSplitMeasuresRunes(t *testing.T) {
+	// 100 characters of 3 bytes each fit a chunk of 100 runes
+	text := strings.Repeat("語", 100)
+	assert.Equal(t, []string{text}, NewRecursiveCharacterTextSplitter(101, 0).SplitText(text))
+	assert.Len(t, NewRecursiveCharacterTextSplitter(101, 0, WithLengthFunction(ByteLength)).SplitText(text), 4)
+}
+
+func TestSplitInTokens(t *testing.T) {
+	wp := NewWordPiece(testVocab)
+	text := strings.Repeat("The proxy listens on port 3128. ", 20)
+
+	chunks := NewRecursiveCharacterTextSplitter(20, 4, WithLengthFunction(wp.Count)).SplitText(text)
+	assert.Greater(t, len(chunks), 1)
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, wp.Count(chunk), 20)
+	}
+	assert.GreaterOrEqual(t, wp.Count(chunks[0]), 16)
+}
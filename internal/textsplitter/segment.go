@@ -0,0 +1,89 @@
+package textsplitter
+
+import (
+	"sort"
+	"strings"
+)
+
+// Segment is a chunk of a text along with where it was found in that text.
+type Segment struct {
+	Text string
+	// Start and End are the byte offsets of the chunk in the text.
+	Start int
+	End   int
+	// StartLine and EndLine are the 1-based lines the chunk starts and ends on.
+	StartLine int
+	EndLine   int
+	// Breadcrumb is the path of headings the chunk lies under, such as "Setup > Linux > Proxy",
+	// for splitters that know the structure of the text.
+	Breadcrumb string
+	// Symbol is the name of the declaration the chunk holds, such as "Store.Search", for splitters
+	// that chunk source code by declaration.
+	Symbol string
+}
+
+// Locate finds where every chunk lies in text. The chunks must be substrings of text in the order
+// they appear in it, possibly overlapping, which is what the splitters of this package return.
+// A chunk that cannot be found is placed right after the start of the previous one.
+func Locate(text string, chunks []string) []Segment {
+	segments := make([]Segment, 0, len(chunks))
+	// lines counts the line breaks before pos
+	pos, lines := 0, 0
+	for _, chunk := range chunks {
+		start := pos
+		if i := strings.Index(text[pos:], chunk); i != -1 {
+			start = pos + i
+		} else if i := strings.Index(text, chunk); i != -1 {
+			start = i
+		}
+
+		if start >= pos {
+			lines += strings.Count(text[pos:start], "\n")
+		} else {
+			lines = strings.Count(text[:start], "\n")
+		}
+		pos = start
+
+		segments = append(segments, Segment{
+			Text:      chunk,
+			Start:     start,
+			End:       min(start+len(chunk), len(text)),
+			StartLine: lines + 1,
+			EndLine:   lines + 1 + strings.Count(chunk, "\n"),
+		})
+
+		// the next chunk starts after this one does, even when they overlap
+		if pos < len(text) && len(chunk) > 0 {
+			lines += strings.Count(text[pos:pos+1], "\n")
+			pos++
+		}
+	}
+	return segments
+}
+
+func newSegment(text string, lines []int, start, end int, breadcrumb string) Segment {
+	return Segment{
+		Text:       text[start:end],
+		Start:      start,
+		End:        end,
+		StartLine:  lineOf(lines, start),
+		EndLine:    lineOf(lines, max(start, end-1)),
+		Breadcrumb: breadcrumb,
+	}
+}
+
+// lineStarts returns the byte offsets every line of text starts at.
+func lineStarts(text string) []int {
+	starts := []int{0}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
+
+// lineOf returns the 1-based line the byte offset lies on.
+func lineOf(lines []int, offset int) int {
+	return sort.Search(len(lines), func(i int) bool { return lines[i] > offset })
+}
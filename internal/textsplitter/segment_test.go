@@ -0,0 +1,39 @@
+package textsplitter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocate(t *testing.T) {
+	text := "first line\nsecond line\n\nthird paragraph\nlast"
+	segments := Locate(text, []string{"first line\nsecond line", "second line\n\nthird", "last"})
+
+	assert.Equal(t, []Segment{
+		{Text: "first line\nsecond line", Start: 0, End: 22, StartLine: 1, EndLine: 2},
+		{Text: "second line\n\nthird", Start: 11, End: 29, StartLine: 2, EndLine: 4},
+		{Text: "last", Start: 40, End: 44, StartLine: 5, EndLine: 5},
+	}, segments)
+}
+
+func TestLocateRepeatedChunks(t *testing.T) {
+	segments := Locate("abc abc abc", []string{"abc", "abc", "abc"})
+	assert.Equal(t, 0, segments[0].Start)
+	assert.Equal(t, 4, segments[1].Start)
+	assert.Equal(t, 8, segments[2].Start)
+}
+
+func TestSplitLocatesChunks(t *testing.T) {
+	text := strings.Repeat("lorem ipsum dolor sit amet.\n", 40) + "\n\n" + strings.Repeat("consectetur adipiscing elit ", 30)
+	splitter := NewRecursiveCharacterTextSplitter(200, 50)
+
+	segments := splitter.Split(text)
+	assert.Greater(t, len(segments), 1)
+	for _, seg := range segments {
+		assert.Equal(t, seg.Text, text[seg.Start:seg.End])
+		assert.Equal(t, strings.Count(text[:seg.Start], "\n")+1, seg.StartLine)
+		assert.Equal(t, strings.Count(text[:seg.End], "\n")+1, seg.EndLine)
+	}
+}
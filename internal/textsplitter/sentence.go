@@ -0,0 +1,236 @@
+package textsplitter
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SentenceTextSplitter packs whole sentences into chunks, so that prose is never cut in the
+// middle of a sentence. Consecutive chunks overlap by the last sentences of the former that fit
+// within the chunk overlap, rather than by a number of characters. Sentences longer than a chunk
+// are split like the RecursiveCharacterTextSplitter does.
+type SentenceTextSplitter struct {
+	TextSplitter
+}
+
+func NewSentenceTextSplitter(chunkSize int, chunkOverlap int, opts ...Option) *SentenceTextSplitter {
+	return &SentenceTextSplitter{TextSplitter: newTextSplitter(chunkSize, chunkOverlap, opts)}
+}
+
+// Split splits text into chunks of whole sentences, each along with where it lies in text.
+func (s *SentenceTextSplitter) Split(text string) []Segment {
+	lines := lineStarts(text)
+	var (
+		segments []Segment
+		// packed are the sentences of the chunk being filled
+		packed []Segment
+	)
+	flush := func() {
+		if len(packed) > 0 {
+			segments = append(segments, newSegment(text, lines, packed[0].Start, packed[len(packed)-1].End, ""))
+		}
+	}
+
+	for _, sentence := range Sentences(text) {
+		if s.lengthFunction(sentence.Text) > s.chunkSize {
+			flush()
+			packed = nil
+			segments = append(segments, s.splitRange(text, lines, sentence.Start, sentence.End)...)
+			continue
+		}
+		if len(packed) > 0 && s.lengthFunction(text[packed[0].Start:sentence.End]) > s.chunkSize {
+			flush()
+			packed = s.overlap(text, packed, sentence)
+		}
+		packed = append(packed, sentence)
+	}
+	flush()
+	return segments
+}
+
+// overlap returns the last sentences of a full chunk that fit within the chunk overlap, and that
+// leave room in the next chunk for the sentence that starts it.
+func (s *SentenceTextSplitter) overlap(text string, packed []Segment, next Segment) []Segment {
+	end := packed[len(packed)-1].End
+	keep := len(packed)
+	for keep > 0 {
+		start := packed[keep-1].Start
+		if s.lengthFunction(text[start:end]) > s.chunkOverlap || s.lengthFunction(text[start:next.End]) > s.chunkSize {
+			break
+		}
+		keep--
+	}
+	return append([]Segment(nil), packed[keep:]...)
+}
+
+// Sentences splits text into its sentences, each along with where it lies in text.
+//
+// A sentence ends at terminal punctuation followed by whitespace, such as the full stop, question
+// and exclamation marks, the ellipsis, and their counterparts in other scripts, along with the
+// closing quotes and brackets after them. Full-width punctuation, as used in Chinese and Japanese,
+// needs no whitespace after it. A sentence does not end before a lowercase word, at a decimal
+// point, after an initial or after a known abbreviation such as "Dr." or "e.g.", while a blank
+// line always ends one, so that headings and list items without punctuation stand on their own.
+func Sentences(text string) []Segment {
+	lines := lineStarts(text)
+	var segments []Segment
+	start := 0
+	end := func(at int) {
+		if s, e := trimRange(text, start, at); s < e {
+			segments = append(segments, newSegment(text, lines, s, e, ""))
+		}
+		start = at
+	}
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case r == '\n':
+			next := i + size
+			for next < len(text) && (text[next] == ' ' || text[next] == '\t' || text[next] == '\r') {
+				next++
+			}
+			if next < len(text) && text[next] == '\n' {
+				end(i)
+				i = next
+				continue
+			}
+		case isTerminal(r):
+			// the whole run of punctuation, such as "?!" or "...", and the quotes closing the sentence
+			after := i + size
+			for after < len(text) {
+				r, size := utf8.DecodeRuneInString(text[after:])
+				if !isTerminal(r) && !isClosing(r) {
+					break
+				}
+				after += size
+			}
+			if endsSentence(text, i, after) {
+				end(after)
+			}
+			i = after
+			continue
+		}
+		i += size
+	}
+	end(len(text))
+	return segments
+}
+
+// endsSentence reports whether the punctuation between the byte offsets at and after of text ends
+// a sentence.
+func endsSentence(text string, at, after int) bool {
+	r, _ := utf8.DecodeRuneInString(text[at:])
+	if isFullWidthTerminal(r) || after == len(text) {
+		return true
+	}
+	if r, _ := utf8.DecodeRuneInString(text[after:]); !unicode.IsSpace(r) {
+		return false
+	}
+
+	next := nextWordStart(text[after:])
+	if unicode.IsLower(next) {
+		return false
+	}
+	// only a single full stop is taken for an abbreviation, not an ellipsis
+	if r != '.' || (after > at+1 && isTerminal(rune(text[at+1]))) {
+		return true
+	}
+
+	word := strings.ToLower(wordBefore(text, at))
+	switch {
+	case abbreviations[word]:
+		return false
+	case numberAbbreviations[word] && unicode.IsDigit(next):
+		return false
+	case utf8.RuneCountInString(word) == 1 && unicode.IsUpper(next):
+		// an initial, as in "J. R. R. Tolkien"
+		return false
+	}
+	return true
+}
+
+// nextWordStart returns the first letter or digit of text, past whitespace and opening quotes.
+func nextWordStart(text string) rune {
+	for _, r := range text {
+		if !unicode.IsSpace(r) && !isOpening(r) {
+			return r
+		}
+	}
+	return 0
+}
+
+// wordBefore returns the word ending at the byte offset end of text, along with the full stops
+// within it, as in "e.g".
+func wordBefore(text string, end int) string {
+	start := end
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(text[:start])
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.' {
+			break
+		}
+		start -= size
+	}
+	return strings.TrimLeft(text[start:end], ".")
+}
+
+func isTerminal(r rune) bool {
+	switch r {
+	case '.', '!', '?', '…', '‼', '⁇', '⁈', '⁉',
+		';',      // Greek question mark
+		'؟', '۔', // Arabic question mark, Urdu full stop
+		'।', '॥', // Devanagari danda and double danda
+		'።', '፧', // Ethiopic full stop and question mark
+		'။': // Myanmar full stop
+		return true
+	}
+	return isFullWidthTerminal(r)
+}
+
+// isFullWidthTerminal reports whether r is the punctuation ending sentences of Chinese and
+// Japanese, which are not followed by spaces.
+func isFullWidthTerminal(r rune) bool {
+	switch r {
+	case '。', '！', '？', '｡', '．':
+		return true
+	}
+	return false
+}
+
+func isClosing(r rune) bool {
+	switch r {
+	case '"', '\'', ')', ']', '}', '”', '’', '»', '›', '」', '』', '）', '】', '〉', '》':
+		return true
+	}
+	return false
+}
+
+func isOpening(r rune) bool {
+	switch r {
+	case '"', '\'', '(', '[', '{', '“', '‘', '«', '‹', '„', '¿', '¡', '「', '『', '（', '【', '〈', '《':
+		return true
+	}
+	return false
+}
+
+// abbreviations are the words whose full stop never ends a sentence, such as titles, which are
+// followed by a name, in English, Portuguese, Spanish, French and German.
+var abbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "dra": true, "prof": true, "profa": true,
+	"sr": true, "sra": true, "srta": true, "jr": true, "st": true, "mt": true, "rev": true,
+	"gen": true, "col": true, "capt": true, "lt": true, "sgt": true, "hon": true, "gov": true,
+	"sen": true, "rep": true, "mme": true, "mlle": true, "hr": true, "fr": true, "ud": true,
+	"uds": true, "exmo": true, "exma": true,
+	"e.g": true, "i.e": true, "cf": true, "vs": true, "approx": true, "ca": true, "viz": true,
+	"p.ex": true, "z.b": true, "d.h": true, "u.a": true, "bzw": true, "vgl": true, "ggf": true,
+}
+
+// numberAbbreviations are the words whose full stop does not end a sentence when a number
+// follows, as in "Fig. 3".
+var numberAbbreviations = map[string]bool{
+	"fig": true, "figs": true, "no": true, "nos": true, "nr": true, "n": true, "nº": true,
+	"vol": true, "p": true, "pp": true, "pg": true, "pág": true, "págs": true, "cap": true,
+	"ch": true, "chap": true, "art": true, "arts": true, "sec": true, "eq": true, "ed": true,
+	"tab": true, "abb": true, "s": true, "op": true,
+}
@@ -0,0 +1,117 @@
+package textsplitter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sentenceTexts(t *testing.T, text string) []string {
+	t.Helper()
+	var texts []string
+	for _, seg := range Sentences(text) {
+		assert.Equal(t, seg.Text, text[seg.Start:seg.End])
+		texts = append(texts, seg.Text)
+	}
+	return texts
+}
+
+func TestSentences(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{
+			name: "terminal punctuation",
+			text: "It works. Does it? It does!  Really...  Yes.",
+			want: []string{"It works.", "Does it?", "It does!", "Really...", "Yes."},
+		},
+		{
+			name: "abbreviations and initials",
+			text: "Dr. Smith met Mr. J. R. R. Tolkien, e.g. at Oxford. See Fig. 3 for details. It ended etc. Then it began.",
+			want: []string{"Dr. Smith met Mr. J. R. R. Tolkien, e.g. at Oxford.", "See Fig. 3 for details.", "It ended etc.", "Then it began."},
+		},
+		{
+			name: "decimals and file names",
+			text: "Pi is 3.14 and the file is config.yaml. Version 1.2.3 is out.",
+			want: []string{"Pi is 3.14 and the file is config.yaml.", "Version 1.2.3 is out."},
+		},
+		{
+			name: "quotes",
+			text: `He said "Stop." Then he left. "Why?" she asked. "Wait!" he said, (sure.) Fine.`,
+			want: []string{`He said "Stop."`, "Then he left.", `"Why?" she asked.`, `"Wait!" he said, (sure.)`, "Fine."},
+		},
+		{
+			name: "lowercase continuations",
+			text: "Wait... what happened? nothing much.",
+			want: []string{"Wait... what happened? nothing much."},
+		},
+		{
+			name: "paragraphs",
+			text: "Introduction\n\nThe text goes on\nacross lines. And ends.\n \n- a list item",
+			want: []string{"Introduction", "The text goes on\nacross lines.", "And ends.", "- a list item"},
+		},
+		{
+			name: "other languages",
+			text: "O Sr. Silva chegou. ¿Qué hora es? Son las tres. 今日は晴れです。明日は雨です！ क्या हाल है। ठीक है। هل أنت بخير؟ نعم.",
+			want: []string{"O Sr. Silva chegou.", "¿Qué hora es?", "Son las tres.", "今日は晴れです。", "明日は雨です！", "क्या हाल है।", "ठीक है।", "هل أنت بخير؟", "نعم."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, sentenceTexts(t, tt.text))
+		})
+	}
+}
+
+func TestSentencePacksWholeSentences(t *testing.T) {
+	text := "The first sentence is here. The second one follows it. A third comes next. The fourth ends it."
+	segments := NewSentenceTextSplitter(60, 0).Split(text)
+
+	assert.Len(t, segments, 2)
+	assert.Equal(t, "The first sentence is here. The second one follows it.", segments[0].Text)
+	assert.Equal(t, "A third comes next. The fourth ends it.", segments[1].Text)
+	assert.Equal(t, segments[1].Text, text[segments[1].Start:segments[1].End])
+}
+
+// texts returns the text of every segment.
+func texts(segments []Segment) []string {
+	chunks := make([]string, len(segments))
+	for i, seg := range segments {
+		chunks[i] = seg.Text
+	}
+	return chunks
+}
+
+func TestSentenceOverlap(t *testing.T) {
+	text := "One is short. Two is short. Three is short. Four is short. Five is short."
+	chunks := texts(NewSentenceTextSplitter(45, 15).Split(text))
+
+	assert.Equal(t, []string{
+		"One is short. Two is short. Three is short.",
+		"Three is short. Four is short. Five is short.",
+	}, chunks)
+
+	// no overlap fits when the last sentence is longer than it
+	chunks = texts(NewSentenceTextSplitter(45, 10).Split(text))
+	assert.Equal(t, []string{
+		"One is short. Two is short. Three is short.",
+		"Four is short. Five is short.",
+	}, chunks)
+}
+
+func TestSentenceSplitsLongSentences(t *testing.T) {
+	long := "Then" + strings.Repeat(" word", 30) + "."
+	text := "Short one. " + long + " Short two."
+	segments := NewSentenceTextSplitter(40, 0).Split(text)
+
+	assert.Equal(t, "Short one.", segments[0].Text)
+	assert.Equal(t, "Short two.", segments[len(segments)-1].Text)
+	assert.Greater(t, len(segments), 3)
+	for _, seg := range segments {
+		assert.LessOrEqual(t, RuneLength(seg.Text), 40)
+		assert.Equal(t, seg.Text, text[seg.Start:seg.End])
+	}
+}
@@ -4,6 +4,19 @@ import (
 	"strings"
 )
 
+// Splitter splits a text into chunks, along with where they lie in the text.
+type Splitter interface {
+	Split(text string) []Segment
+}
+
+var (
+	_ Splitter = &RecursiveCharacterTextSplitter{}
+	_ Splitter = &MarkdownTextSplitter{}
+	_ Splitter = &CodeTextSplitter{}
+	_ Splitter = &SentenceTextSplitter{}
+)
+
+// LenFunction measures the length of a text in the unit chunk sizes are given in.
 type LenFunction func(string) int
 
 type TextSplitter struct {
@@ -12,6 +25,34 @@ type TextSplitter struct {
 	lengthFunction LenFunction
 }
 
+// Option configures a splitter when it is created.
+type Option func(*TextSplitter)
+
+// WithLengthFunction measures chunks with lengthFunction instead of counting their runes, such as
+// the Count of a WordPiece tokenizer to size chunks in tokens.
+func WithLengthFunction(lengthFunction LenFunction) Option {
+	return func(t *TextSplitter) {
+		t.lengthFunction = lengthFunction
+	}
+}
+
+func newTextSplitter(chunkSize int, chunkOverlap int, opts []Option) TextSplitter {
+	t := TextSplitter{
+		chunkSize:      chunkSize,
+		chunkOverlap:   chunkOverlap,
+		lengthFunction: defaultLengthFunction,
+	}
+	for _, opt := range opts {
+		opt(&t)
+	}
+	return t
+}
+
+// Length measures text in the unit chunk sizes are given in.
+func (t *TextSplitter) Length(text string) int {
+	return t.lengthFunction(text)
+}
+
 func (t *TextSplitter) mergeSplits(splits []string, separator string) []string {
 	docs := make([]string, 0)
 	currentDoc := make([]string, 0)
@@ -19,20 +60,20 @@ func (t *TextSplitter) mergeSplits(splits []string, separator string) []string {
 	for _, d := range splits {
 		splitLen := t.lengthFunction(d)
 
-		if total+splitLen+getSLen(currentDoc, separator, 0) > t.chunkSize {
+		if total+splitLen+t.separatorLen(currentDoc, separator, 0) > t.chunkSize {
 			if len(currentDoc) > 0 {
 				doc := t.joinDocs(currentDoc, separator)
 				if doc != "" {
 					docs = append(docs, doc)
 				}
-				for (total > t.chunkOverlap) || (getSLen(currentDoc, separator, 0) > t.chunkSize) && total > 0 {
-					total -= t.lengthFunction(currentDoc[0]) + getSLen(currentDoc, separator, 1)
+				for (total > t.chunkOverlap) || (t.separatorLen(currentDoc, separator, 0) > t.chunkSize) && total > 0 {
+					total -= t.lengthFunction(currentDoc[0]) + t.separatorLen(currentDoc, separator, 1)
 					currentDoc = currentDoc[1:]
 				}
 			}
 		}
 		currentDoc = append(currentDoc, d)
-		total += getSLen(currentDoc, separator, 1)
+		total += t.separatorLen(currentDoc, separator, 1)
 		total += splitLen
 	}
 	doc := t.joinDocs(currentDoc, separator)
@@ -47,10 +88,26 @@ func (t *TextSplitter) joinDocs(docs []string, separator string) string {
 	return strings.TrimSpace(text)
 }
 
-func getSLen(currentDoc []string, separator string, compareLen int) int {
+func (t *TextSplitter) separatorLen(currentDoc []string, separator string, compareLen int) int {
 	if len(currentDoc) > compareLen {
-		return len(separator)
+		return t.lengthFunction(separator)
 	}
 
 	return 0
 }
+
+// splitRange splits text[start:end], too long for a chunk, with the recursive splitter, placing
+// the chunks where they lie in text.
+func (t *TextSplitter) splitRange(text string, lines []int, start, end int) []Segment {
+	splitter := NewRecursiveCharacterTextSplitter(t.chunkSize, t.chunkOverlap, WithLengthFunction(t.lengthFunction))
+	segments := splitter.Split(text[start:end])
+
+	firstLine := lineOf(lines, start)
+	for i := range segments {
+		segments[i].Start += start
+		segments[i].End += start
+		segments[i].StartLine += firstLine - 1
+		segments[i].EndLine += firstLine - 1
+	}
+	return segments
+}
@@ -0,0 +1,158 @@
+package textsplitter
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// wordPieceContinuation marks the pieces of a word that do not start it.
+	wordPieceContinuation = "##"
+	wordPieceUnknown      = "[UNK]"
+	// maxWordPieceRunes is the length past which a word is not split into pieces but taken as unknown.
+	maxWordPieceRunes = 100
+)
+
+// WordPiece tokenizes texts the way BERT models and their many embedding descendants do, to
+// measure chunks in the tokens the model sees. Words are split on whitespace and punctuation, and
+// every word into the longest pieces found in the vocabulary, or taken as a single unknown token.
+// Vocabularies without uppercase letters are those of uncased models, which lowercase texts
+// first. Accents are kept, so counts may differ slightly from those of uncased models that strip them.
+type WordPiece struct {
+	vocab     map[string]struct{}
+	lowercase bool
+}
+
+// NewWordPiece returns a tokenizer for the given vocabulary.
+func NewWordPiece(vocab []string) *WordPiece {
+	wp := &WordPiece{vocab: make(map[string]struct{}, len(vocab)), lowercase: true}
+	for _, token := range vocab {
+		wp.vocab[token] = struct{}{}
+		if !isSpecialToken(token) && strings.ToLower(token) != token {
+			wp.lowercase = false
+		}
+	}
+	return wp
+}
+
+// LoadWordPiece reads a vocabulary file holding one token per line, such as the vocab.txt
+// published with BERT models.
+func LoadWordPiece(path string) (*WordPiece, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var vocab []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if token := strings.TrimRight(scanner.Text(), "\r"); token != "" {
+			vocab = append(vocab, token)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read vocabulary: %w", err)
+	}
+	if len(vocab) == 0 {
+		return nil, fmt.Errorf("vocabulary %q is empty", path)
+	}
+	return NewWordPiece(vocab), nil
+}
+
+func isSpecialToken(token string) bool {
+	return strings.HasPrefix(token, "[") && strings.HasSuffix(token, "]")
+}
+
+// Tokenize returns the tokens of text.
+func (wp *WordPiece) Tokenize(text string) []string {
+	var tokens []string
+	for _, word := range wp.words(text) {
+		tokens = append(tokens, wp.pieces(word)...)
+	}
+	return tokens
+}
+
+// Count returns the number of tokens of text. It is the LenFunction of the tokenizer.
+func (wp *WordPiece) Count(text string) int {
+	count := 0
+	for _, word := range wp.words(text) {
+		count += len(wp.pieces(word))
+	}
+	return count
+}
+
+// words splits text on whitespace, and around punctuation and CJK characters, which are words of their own.
+func (wp *WordPiece) words(text string) []string {
+	if wp.lowercase {
+		text = strings.ToLower(text)
+	}
+
+	var words []string
+	start := -1
+	for i, r := range text {
+		switch {
+		case unicode.IsSpace(r) || unicode.IsControl(r) || r == utf8.RuneError:
+			if start >= 0 {
+				words = append(words, text[start:i])
+				start = -1
+			}
+		case isWordPieceBoundary(r):
+			if start >= 0 {
+				words = append(words, text[start:i])
+				start = -1
+			}
+			words = append(words, string(r))
+		default:
+			if start < 0 {
+				start = i
+			}
+		}
+	}
+	if start >= 0 {
+		words = append(words, text[start:])
+	}
+	return words
+}
+
+func isWordPieceBoundary(r rune) bool {
+	if r < 128 {
+		return (r >= '!' && r <= '/') || (r >= ':' && r <= '@') || (r >= '[' && r <= '`') || (r >= '{' && r <= '~')
+	}
+	return unicode.IsPunct(r) || unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// pieces splits a word greedily into the longest pieces of the vocabulary.
+func (wp *WordPiece) pieces(word string) []string {
+	if utf8.RuneCountInString(word) > maxWordPieceRunes {
+		return []string{wordPieceUnknown}
+	}
+
+	var pieces []string
+	for start := 0; start < len(word); {
+		end := len(word)
+		piece := ""
+		for end > start {
+			candidate := word[start:end]
+			if start > 0 {
+				candidate = wordPieceContinuation + candidate
+			}
+			if _, ok := wp.vocab[candidate]; ok {
+				piece = candidate
+				break
+			}
+			_, size := utf8.DecodeLastRuneInString(word[start:end])
+			end -= size
+		}
+		if piece == "" {
+			return []string{wordPieceUnknown}
+		}
+		pieces = append(pieces, piece)
+		start = end
+	}
+	return pieces
+}
@@ -0,0 +1,41 @@
+package textsplitter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testVocab = []string{"[PAD]", "[UNK]", "[CLS]", "[SEP]", "the", "proxy", "listen", "##s", "on", "port", "31", "##28", ".", ",", "un", "##aff", "##able", "über", "東", "京"}
+
+func TestWordPieceTokenize(t *testing.T) {
+	wp := NewWordPiece(testVocab)
+
+	assert.Equal(t, []string{"the", "proxy", "listen", "##s", "on", "port", "31", "##28", "."}, wp.Tokenize("The proxy listens on port 3128."))
+	assert.Equal(t, []string{"un", "##aff", "##able", ","}, wp.Tokenize("unaffable,"))
+	// words without pieces in the vocabulary are a single unknown token
+	assert.Equal(t, []string{"[UNK]", "the"}, wp.Tokenize("zebras the"))
+	assert.Equal(t, []string{"über", "東", "京"}, wp.Tokenize("Über東京"))
+	assert.Equal(t, 0, wp.Count(" \n\t"))
+	assert.Equal(t, 9, wp.Count("The proxy listens on port 3128."))
+}
+
+func TestWordPieceCasedVocabulary(t *testing.T) {
+	wp := NewWordPiece([]string{"[UNK]", "The", "the"})
+	assert.Equal(t, []string{"The", "the", "[UNK]"}, wp.Tokenize("The the THE"))
+}
+
+func TestLoadWordPiece(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vocab.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("[UNK]\r\nhello\r\n##s\r\n"), 0o644))
+
+	wp, err := LoadWordPiece(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"hello", "##s"}, wp.Tokenize("hellos"))
+
+	assert.NoError(t, os.WriteFile(path, nil, 0o644))
+	_, err = LoadWordPiece(path)
+	assert.ErrorContains(t, err, "empty")
+}
@@ -0,0 +1,113 @@
+package vector
+
+import (
+	"math"
+	"math/rand"
+	"runtime"
+	"sync"
+)
+
+// SquaredDistance returns the squared Euclidean distance between a and b.
+func SquaredDistance(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return float32(math.Inf(1))
+	}
+	var sum float32
+	for i, v := range a {
+		d := v - b[i]
+		sum += d * d
+	}
+	return sum
+}
+
+// Nearest returns the index of the centroid closest to v, or -1 if there are no centroids.
+func Nearest(centroids [][]float32, v []float32) int {
+	best, bestDist := -1, float32(math.Inf(1))
+	for i, c := range centroids {
+		if d := SquaredDistance(c, v); d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+// KMeans clusters vecs into k centroids with Lloyd's algorithm, starting from k distinct
+// vectors picked at random. Clusters that end up empty are reseeded with a random vector.
+func KMeans(vecs [][]float32, k, iterations int, rng *rand.Rand) [][]float32 {
+	if k <= 0 || len(vecs) == 0 {
+		return nil
+	}
+	k = min(k, len(vecs))
+	dim := len(vecs[0])
+
+	centroids := make([][]float32, k)
+	for i, p := range rng.Perm(len(vecs))[:k] {
+		centroids[i] = append([]float32(nil), vecs[p]...)
+	}
+
+	assignments := make([]int, len(vecs))
+	for iter := 0; iter < iterations; iter++ {
+		if !assign(centroids, vecs, assignments) && iter > 0 {
+			break
+		}
+
+		counts := make([]int, k)
+		sums := make([][]float64, k)
+		for i := range sums {
+			sums[i] = make([]float64, dim)
+		}
+		for i, v := range vecs {
+			c := assignments[i]
+			counts[c]++
+			for j, x := range v {
+				sums[c][j] += float64(x)
+			}
+		}
+
+		for c := range centroids {
+			if counts[c] == 0 {
+				copy(centroids[c], vecs[rng.Intn(len(vecs))])
+				continue
+			}
+			for j := range centroids[c] {
+				centroids[c][j] = float32(sums[c][j] / float64(counts[c]))
+			}
+		}
+	}
+
+	return centroids
+}
+
+// assign stores the nearest centroid of every vector in assignments, spreading the work
+// over all CPUs. It reports whether any assignment changed.
+func assign(centroids, vecs [][]float32, assignments []int) bool {
+	workers := runtime.NumCPU()
+	size := (len(vecs) + workers - 1) / workers
+
+	var wg sync.WaitGroup
+	changed := make([]bool, workers)
+	for w := 0; w < workers; w++ {
+		start, end := w*size, min((w+1)*size, len(vecs))
+		if start >= end {
+			break
+		}
+		wg.Add(1)
+		go func(w, start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				if c := Nearest(centroids, vecs[i]); c != assignments[i] {
+					assignments[i] = c
+					changed[w] = true
+				}
+			}
+		}(w, start, end)
+	}
+	wg.Wait()
+
+	for _, c := range changed {
+		if c {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+package vector
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSquaredDistance(t *testing.T) {
+	assert.Equal(t, float32(25), SquaredDistance([]float32{0, 0}, []float32{3, 4}))
+	assert.Equal(t, float32(0), SquaredDistance([]float32{1, 2}, []float32{1, 2}))
+}
+
+func TestKMeansFindsClusters(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	centers := [][]float32{{10, 0}, {0, 10}, {-10, -10}}
+
+	var vecs [][]float32
+	for _, c := range centers {
+		for i := 0; i < 50; i++ {
+			vecs = append(vecs, []float32{c[0] + rng.Float32() - 0.5, c[1] + rng.Float32() - 0.5})
+		}
+	}
+
+	centroids := KMeans(vecs, 3, 20, rng)
+	assert.Len(t, centroids, 3)
+	for _, c := range centers {
+		nearest := centroids[Nearest(centroids, c)]
+		assert.Less(t, SquaredDistance(nearest, c), float32(1))
+	}
+}
@@ -0,0 +1,114 @@
+package vector
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+)
+
+// pqCentroids is the number of centroids per subspace, so that a code fits in a byte.
+const pqCentroids = 256
+
+// ProductQuantizer compresses vectors to one byte per subspace: a vector is split into
+// consecutive subvectors, and each is replaced by the index of the closest centroid of
+// the codebook trained for its subspace.
+type ProductQuantizer struct {
+	Dim int
+	// Codebooks holds the centroids of every subspace.
+	Codebooks [][][]float32
+}
+
+// TrainProductQuantizer trains a quantizer splitting vectors into subspaces parts, running
+// k-means for the given number of iterations over vecs in each subspace.
+func TrainProductQuantizer(vecs [][]float32, subspaces, iterations int, rng *rand.Rand) (*ProductQuantizer, error) {
+	if len(vecs) == 0 {
+		return nil, errors.New("no vectors to train on")
+	}
+	dim := len(vecs[0])
+	if subspaces <= 0 || subspaces > dim {
+		return nil, fmt.Errorf("cannot split %d dimensions into %d subspaces", dim, subspaces)
+	}
+	for _, v := range vecs {
+		if len(v) != dim {
+			return nil, errors.New("vectors must have the same length")
+		}
+	}
+
+	pq := &ProductQuantizer{Dim: dim, Codebooks: make([][][]float32, subspaces)}
+	sub := make([][]float32, len(vecs))
+	for m := range pq.Codebooks {
+		start, end := pq.subspace(m)
+		for i, v := range vecs {
+			sub[i] = v[start:end]
+		}
+		pq.Codebooks[m] = KMeans(sub, pqCentroids, iterations, rng)
+	}
+	return pq, nil
+}
+
+// Subspaces returns the number of subspaces, which is also the length of a code.
+func (pq *ProductQuantizer) Subspaces() int {
+	return len(pq.Codebooks)
+}
+
+// subspace returns the range of the components of a vector making up subspace m.
+func (pq *ProductQuantizer) subspace(m int) (int, int) {
+	n := len(pq.Codebooks)
+	return m * pq.Dim / n, (m + 1) * pq.Dim / n
+}
+
+// Encode returns the code of v, the index of the closest centroid in every subspace.
+// It fails if v does not have the dimension the quantizer was trained for.
+func (pq *ProductQuantizer) Encode(v []float32) ([]byte, error) {
+	if len(v) != pq.Dim {
+		return nil, fmt.Errorf("cannot encode %d dimensions with a quantizer of %d", len(v), pq.Dim)
+	}
+	code := make([]byte, len(pq.Codebooks))
+	for m, codebook := range pq.Codebooks {
+		start, end := pq.subspace(m)
+		code[m] = byte(Nearest(codebook, v[start:end]))
+	}
+	return code, nil
+}
+
+// Decode returns the approximation of the vector encoded as code.
+func (pq *ProductQuantizer) Decode(code []byte) []float32 {
+	v := make([]float32, pq.Dim)
+	for m, codebook := range pq.Codebooks {
+		start, _ := pq.subspace(m)
+		copy(v[start:], codebook[code[m]])
+	}
+	return v
+}
+
+// ADCTable holds, for every subspace and centroid, the dot product between the query and the centroid.
+// It scores codes against a query that is left unquantized (asymmetric distance computation).
+type ADCTable [][]float32
+
+// Table precomputes the dot products between query and every centroid.
+func (pq *ProductQuantizer) Table(query []float32) ADCTable {
+	table := make(ADCTable, len(pq.Codebooks))
+	if len(query) != pq.Dim {
+		return table
+	}
+	for m, codebook := range pq.Codebooks {
+		start, end := pq.subspace(m)
+		table[m] = make([]float32, len(codebook))
+		for c, centroid := range codebook {
+			table[m][c] = FastCosineSimilarity(query[start:end], centroid)
+		}
+	}
+	return table
+}
+
+// Score approximates the dot product between the query of the table and the vector encoded as code.
+// For unit vectors, it approximates their cosine similarity.
+func (t ADCTable) Score(code []byte) float32 {
+	var score float32
+	for m, c := range code {
+		if m < len(t) && int(c) < len(t[m]) {
+			score += t[m][c]
+		}
+	}
+	return score
+}
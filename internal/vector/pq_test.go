@@ -0,0 +1,66 @@
+package vector
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func randomUnitVectors(rng *rand.Rand, n, dim int) [][]float32 {
+	vecs := make([][]float32, n)
+	for i := range vecs {
+		v := make([]float32, dim)
+		for j := range v {
+			v[j] = float32(rng.NormFloat64())
+		}
+		vecs[i] = Normalize(v)
+	}
+	return vecs
+}
+
+func TestProductQuantizerRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	vecs := randomUnitVectors(rng, 100, 10)
+
+	// with as many centroids as vectors, every vector is its own centroid
+	pq, err := TrainProductQuantizer(vecs, 3, 10, rng)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, pq.Subspaces())
+
+	for _, v := range vecs[:10] {
+		code, err := pq.Encode(v)
+		assert.NoError(t, err)
+		assert.Len(t, code, 3)
+		assert.InDeltaSlice(t, v, pq.Decode(code), 1e-6)
+	}
+
+	_, err = pq.Encode(vecs[0][:5])
+	assert.Error(t, err)
+}
+
+func TestADCTableScoresMatchDecodedDotProduct(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	vecs := randomUnitVectors(rng, 1000, 32)
+	pq, err := TrainProductQuantizer(vecs, 8, 10, rng)
+	assert.NoError(t, err)
+
+	query := randomUnitVectors(rng, 1, 32)[0]
+	table := pq.Table(query)
+	for _, v := range vecs[:20] {
+		code, err := pq.Encode(v)
+		assert.NoError(t, err)
+		assert.InDelta(t, FastCosineSimilarity(query, pq.Decode(code)), table.Score(code), 1e-5)
+		assert.InDelta(t, FastCosineSimilarity(query, v), table.Score(code), 0.2)
+	}
+}
+
+func TestTrainProductQuantizerRejectsBadSubspaces(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	vecs := randomUnitVectors(rng, 10, 4)
+
+	_, err := TrainProductQuantizer(vecs, 5, 10, rng)
+	assert.Error(t, err)
+	_, err = TrainProductQuantizer(nil, 2, 10, rng)
+	assert.Error(t, err)
+}
@@ -0,0 +1,80 @@
+package vector
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Int8Vector is a vector quantized to 8-bit integers sharing a single scale,
+// so that v[i] ≈ Codes[i] * Scale.
+type Int8Vector struct {
+	Codes []int8
+	Scale float32
+}
+
+// QuantizeInt8 quantizes v symmetrically, mapping its largest absolute component to ±127.
+func QuantizeInt8(v []float32) Int8Vector {
+	var maxAbs float32
+	for _, x := range v {
+		maxAbs = max(maxAbs, float32(math.Abs(float64(x))))
+	}
+
+	q := Int8Vector{Codes: make([]int8, len(v))}
+	if maxAbs == 0 {
+		return q
+	}
+
+	q.Scale = maxAbs / 127
+	for i, x := range v {
+		q.Codes[i] = int8(math.Round(float64(x / q.Scale)))
+	}
+	return q
+}
+
+// Dot returns the dot product of the quantized vector with v, which is left unquantized.
+func (q Int8Vector) Dot(v []float32) float32 {
+	if len(q.Codes) != len(v) {
+		return 0
+	}
+	var dot float32
+	for i, c := range q.Codes {
+		dot += float32(c) * v[i]
+	}
+	return dot * q.Scale
+}
+
+// Dequantize returns the approximation of the original vector.
+func (q Int8Vector) Dequantize() []float32 {
+	v := make([]float32, len(q.Codes))
+	for i, c := range q.Codes {
+		v[i] = float32(c) * q.Scale
+	}
+	return v
+}
+
+// BinaryVector holds one bit per component of a vector, set when the component is positive.
+type BinaryVector []uint64
+
+// QuantizeBinary keeps only the sign of every component of v.
+func QuantizeBinary(v []float32) BinaryVector {
+	b := make(BinaryVector, (len(v)+63)/64)
+	for i, x := range v {
+		if x > 0 {
+			b[i/64] |= 1 << (i % 64)
+		}
+	}
+	return b
+}
+
+// HammingDistance counts the bits that differ between a and b. For binary quantized unit vectors,
+// a smaller distance means a smaller angle between the original vectors.
+func HammingDistance(a, b BinaryVector) int {
+	if len(a) != len(b) {
+		return math.MaxInt
+	}
+	var dist int
+	for i, w := range a {
+		dist += bits.OnesCount64(w ^ b[i])
+	}
+	return dist
+}
@@ -0,0 +1,46 @@
+package vector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuantizeInt8(t *testing.T) {
+	v := []float32{0.5, -1, 0.25, 0}
+	q := QuantizeInt8(v)
+
+	assert.Equal(t, []int8{64, -127, 32, 0}, q.Codes)
+	assert.InDeltaSlice(t, v, q.Dequantize(), 0.01)
+	assert.InDelta(t, FastCosineSimilarity(v, v), q.Dot(v), 0.01)
+}
+
+func TestQuantizeInt8Zero(t *testing.T) {
+	q := QuantizeInt8([]float32{0, 0})
+	assert.Equal(t, float32(0), q.Dot([]float32{1, 1}))
+}
+
+func TestInt8DotTracksFloatDot(t *testing.T) {
+	a := Normalize(generateRandomVector(768))
+	b := Normalize(generateRandomVector(768))
+	assert.InDelta(t, FastCosineSimilarity(a, b), QuantizeInt8(a).Dot(b), 0.005)
+}
+
+func TestQuantizeBinary(t *testing.T) {
+	v := make([]float32, 70)
+	v[0], v[3], v[69] = 1, 0.5, 2
+	v[1] = -1
+
+	b := QuantizeBinary(v)
+	assert.Len(t, b, 2)
+	assert.Equal(t, uint64(0b1001), b[0])
+	assert.Equal(t, uint64(1<<5), b[1])
+}
+
+func TestHammingDistance(t *testing.T) {
+	a := QuantizeBinary([]float32{1, 1, -1, -1})
+	b := QuantizeBinary([]float32{1, -1, 1, -1})
+
+	assert.Equal(t, 0, HammingDistance(a, a))
+	assert.Equal(t, 2, HammingDistance(a, b))
+}
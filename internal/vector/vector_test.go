@@ -3,6 +3,8 @@ package vector
 import (
 	"math"
 	"math/rand"
+	"runtime"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -140,3 +142,39 @@ func BenchmarkFastCosineSimilarity_1000(b *testing.B) {
 		FastCosineSimilarity(vecA1000, vecB1000)
 	}
 }
+
+// BenchmarkScan compares every vector of a store-sized set with a query, on one goroutine
+// and sharded over all CPUs the way storage.DiskStore.Search does.
+func BenchmarkScan(b *testing.B) {
+	vecs := make([][]float32, 50_000)
+	for i := range vecs {
+		vecs[i] = generateRandomVector(768)
+	}
+	query := generateRandomVector(768)
+
+	b.Run("Sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, v := range vecs {
+				FastCosineSimilarity(query, v)
+			}
+		}
+	})
+
+	b.Run("Parallel", func(b *testing.B) {
+		workers := runtime.NumCPU()
+		size := (len(vecs) + workers - 1) / workers
+		for i := 0; i < b.N; i++ {
+			var wg sync.WaitGroup
+			for start := 0; start < len(vecs); start += size {
+				wg.Add(1)
+				go func(shard [][]float32) {
+					defer wg.Done()
+					for _, v := range shard {
+						FastCosineSimilarity(query, v)
+					}
+				}(vecs[start:min(start+size, len(vecs))])
+			}
+			wg.Wait()
+		}
+	})
+}